@@ -2,56 +2,113 @@ package aigit
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"path/filepath"
+	"slices"
 	"strings"
 
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 )
 
+// configAnnotation marks flags that override a config key
+const configAnnotation = "aigit-config"
+
 type Cli struct {
-	model  Model
-	git    Git
-	github GitHub
-	root   *cobra.Command
+	config   *Config
+	models   ModelFactory
+	newGit   GitFactory
+	newForge ForgeFactory
+	prompter Prompter
+	root     *cobra.Command
+
+	// git and forge are created by requireGit and requireForge for the commands that need them
+	git   Git
+	forge Forge
 }
 
-func NewCli(model Model, git Git, github GitHub) *Cli {
+// NewCli creates the command line interface. Dependencies are created from the factories only
+// once a command needs them, so that for example committing works without a forge CLI.
+func NewCli(config *Config, models ModelFactory, git GitFactory, forge ForgeFactory) *Cli {
 	cli := &Cli{
-		model:  model,
-		git:    git,
-		github: github,
+		config:   config,
+		models:   models,
+		newGit:   git,
+		newForge: forge,
+		prompter: NewTerminalPrompter(),
 	}
 
 	cli.root = &cobra.Command{
 		Use:   "aigit",
 		Short: "AI-enhanced git CLI",
 		Long:  `A git CLI tool that uses AI to help with common git operations.`,
+
+		PersistentPreRunE: cli.applyFlags,
 	}
+	cli.root.PersistentFlags().BoolP("verbose", "v", false, "explain what aigit is doing")
 
 	commitCmd := &cobra.Command{
-		Use:   "commit",
-		Short: "Create a commit with an AI-generated message",
-		Long:  `Create a commit with a message generated by AI based on the staged changes.`,
-		RunE:  cli.commit,
+		Use:     "commit",
+		Short:   "Create a commit with an AI-generated message",
+		Long:    `Create a commit with a message generated by AI based on the staged changes.`,
+		PreRunE: cli.requireGit,
+		RunE:    cli.commit,
 	}
+	addModelFlags(commitCmd, "commit")
+	commitCmd.Flags().BoolP("yes", "y", false, "commit without reviewing the generated message")
+	commitCmd.Flags().Int("candidates", 0, "generate several messages and pick one")
+	commitCmd.MarkFlagsMutuallyExclusive("yes", "candidates")
+	bindFlag(commitCmd, "candidates", "commit.candidates")
+	addDryRunFlag(commitCmd)
 
 	amendCmd := &cobra.Command{
-		Use:   "amend",
-		Short: "Amend the last commit with staged changes and regenerate the message",
-		Long:  `Amend the last commit with staged changes and generate a new commit message using AI.`,
-		RunE:  cli.amend,
+		Use:     "amend",
+		Short:   "Amend the last commit with staged changes and regenerate the message",
+		Long:    `Amend the last commit with staged changes and generate a new commit message using AI.`,
+		PreRunE: cli.requireGit,
+		RunE:    cli.amend,
 	}
+	addModelFlags(amendCmd, "commit")
+	amendCmd.Flags().BoolP("yes", "y", false, "amend without reviewing the generated message")
+	addDryRunFlag(amendCmd)
 
 	prCmd := &cobra.Command{
-		Use:   "pr",
-		Short: "Create a pull request with an AI-generated description",
-		Long:  `Create a pull request with a description generated by AI based on the commit history.`,
-		RunE:  cli.createPR,
+		Use:     "pr",
+		Short:   "Create a pull request with an AI-generated description",
+		Long:    `Create a pull request with a description generated by AI based on the commit history.`,
+		PreRunE: cli.requireForge,
+		RunE:    cli.createPR,
 	}
+	addModelFlags(prCmd, "pr")
+	addDryRunFlag(prCmd)
+	prCmd.Flags().Bool("force-with-lease", false, "force push without asking if the branch was rejected as non-fast-forward")
+	prCmd.Flags().String("base", "", "base branch of the pull request, detected when empty")
+	bindFlag(prCmd, "base", "pr.base")
+	prCmd.Flags().String("template", "", "pull request template to follow, or \"none\"")
+	bindFlag(prCmd, "template", "pr.template")
+	prCmd.Flags().Bool("draft", false, "open the pull request as a draft")
+	bindFlag(prCmd, "draft", "pr.draft")
+	prCmd.Flags().StringSlice("reviewer", nil, "request a review from a user")
+	bindFlag(prCmd, "reviewer", "pr.reviewers")
+	prCmd.Flags().StringSlice("team-reviewer", nil, "request a review from a team, as org/team")
+	bindFlag(prCmd, "team-reviewer", "pr.team_reviewers")
+	prCmd.Flags().StringSlice("label", nil, "add a label")
+	bindFlag(prCmd, "label", "pr.labels")
+	prCmd.Flags().StringSlice("assignee", nil, "assign a user")
+	bindFlag(prCmd, "assignee", "pr.assignees")
+	prCmd.Flags().String("milestone", "", "add the pull request to a milestone")
+	bindFlag(prCmd, "milestone", "pr.milestone")
+	prCmd.Flags().Bool("suggest-reviewers", false, "request reviews from the CODEOWNERS of the changed files")
+	bindFlag(prCmd, "suggest-reviewers", "pr.suggest_reviewers")
 
 	cli.root.AddCommand(commitCmd)
 	cli.root.AddCommand(amendCmd)
+	prCmd.AddCommand(cli.prReviewCommand())
 	cli.root.AddCommand(prCmd)
+	cli.root.AddCommand(cli.reviewCommand())
+	cli.root.AddCommand(cli.configCommand())
+	cli.root.AddCommand(cli.promptsCommand())
 	return cli
 }
 
@@ -60,6 +117,214 @@ func (cli *Cli) Run(args []string) error {
 	return cli.root.Execute()
 }
 
+// addModelFlags adds flags overriding the model settings of a config section
+func addModelFlags(cmd *cobra.Command, section string) {
+	cmd.Flags().String("model", "", "model name to use")
+	cmd.Flags().Int("max-tokens", 0, "maximum number of tokens to generate")
+	cmd.Flags().Float64("temperature", 0, "sampling temperature")
+	bindFlag(cmd, "model", section+".model")
+	bindFlag(cmd, "max-tokens", section+".max_tokens")
+	bindFlag(cmd, "temperature", section+".temperature")
+}
+
+// addDryRunFlag adds a flag that prints what a command would do instead of doing it
+func addDryRunFlag(cmd *cobra.Command) {
+	cmd.Flags().Bool("dry-run", false, "print the generated content and the commands that would run, without running them")
+}
+
+// isDryRun reports whether the command was invoked with --dry-run
+func isDryRun(cmd *cobra.Command) bool {
+	dryRun, _ := cmd.Flags().GetBool("dry-run")
+	return dryRun
+}
+
+// printDryRun prints the commands a dry run skipped
+func printDryRun(cmd *cobra.Command, commands ...string) {
+	fmt.Fprintln(cmd.OutOrStdout(), "\nDry run, would run:")
+	for _, command := range commands {
+		fmt.Fprintf(cmd.OutOrStdout(), "  %s\n", command)
+	}
+}
+
+// bindFlag marks a flag as overriding the given config key
+func bindFlag(cmd *cobra.Command, flag, key string) {
+	cmd.Flags().SetAnnotation(flag, configAnnotation, []string{key})
+}
+
+// applyFlags copies explicitly set flags into the config, taking precedence over all other sources
+func (cli *Cli) applyFlags(cmd *cobra.Command, args []string) error {
+	var err error
+	cmd.Flags().Visit(func(f *pflag.Flag) {
+		keys, ok := f.Annotations[configAnnotation]
+		if !ok || err != nil {
+			return
+		}
+		value := f.Value.String()
+		if list, ok := f.Value.(pflag.SliceValue); ok {
+			// Lists are stored one item per line
+			value = strings.Join(list.GetSlice(), "\n")
+		}
+		err = cli.config.Set(keys[0], value, SourceFlag+" --"+f.Name)
+	})
+	return err
+}
+
+// requireGit creates the git backend selected by git.backend
+func (cli *Cli) requireGit(cmd *cobra.Command, args []string) error {
+	if cli.git != nil {
+		return nil
+	}
+	git, err := cli.newGit(cli.config.Get("git.backend"))
+	if err != nil {
+		return fmt.Errorf("aigit %s needs git: %w", cmd.Name(), err)
+	}
+	cli.git = git
+	return nil
+}
+
+// requireForge creates the git backend and the forge hosting the repository
+func (cli *Cli) requireForge(cmd *cobra.Command, args []string) error {
+	if err := cli.requireGit(cmd, args); err != nil {
+		return err
+	}
+	if cli.forge != nil {
+		return nil
+	}
+	forge, err := cli.newForge(cli.config, cli.git)
+	if err != nil {
+		return fmt.Errorf("aigit %s needs a forge to open pull requests, set forge.type if it was not detected: %w", cmd.Name(), err)
+	}
+	cli.forge = forge
+	return nil
+}
+
+// model creates the model configured for a config section such as "commit" or "pr"
+func (cli *Cli) model(cmd *cobra.Command, section string) (Model, error) {
+	model, err := cli.models(cli.modelOptions(section))
+	if err != nil {
+		return nil, fmt.Errorf("aigit %s needs a model: %w", cmd.Name(), err)
+	}
+	return model, nil
+}
+
+// verbosef prints progress details when --verbose is given
+func (cli *Cli) verbosef(format string, args ...any) {
+	if verbose, _ := cli.root.PersistentFlags().GetBool("verbose"); verbose {
+		fmt.Fprintf(cli.root.ErrOrStderr(), format+"\n", args...)
+	}
+}
+
+// filterDiff drops files matched by the built-in ignore rules and .aigitignore from a diff
+func (cli *Cli) filterDiff(diff string) (string, error) {
+	// Outside a repository only the built-in rules apply
+	root, _ := RepoRoot()
+	rules, err := LoadIgnore(root)
+	if err != nil {
+		return "", err
+	}
+	filtered, removed := filterDiff(diff, rules)
+	if len(removed) > 0 {
+		cli.verbosef("Omitting contents of %d ignored files: %s", len(removed), strings.Join(removed, ", "))
+	}
+	return filtered, nil
+}
+
+// redactDiff masks suspected secrets in a diff, or refuses to continue, depending on redact.mode
+func (cli *Cli) redactDiff(diff string) (string, error) {
+	return cli.redact(diff, "")
+}
+
+// redactHistory masks suspected secrets in commit messages like redactDiff does in diffs
+func (cli *Cli) redactHistory(history string) (string, error) {
+	return cli.redact(history, "commit messages")
+}
+
+// redact masks suspected secrets in text according to redact.mode. Findings in text other than
+// a diff are reported with the given source in place of a file name.
+func (cli *Cli) redact(text, source string) (string, error) {
+	mode := cli.config.Get("redact.mode")
+	if mode == RedactOff {
+		return text, nil
+	}
+
+	redactor, err := NewRedactor(cli.config.List("redact.patterns"))
+	if err != nil {
+		return "", err
+	}
+	redacted, findings := redactor.Redact(text)
+	if len(findings) == 0 {
+		return text, nil
+	}
+	if source != "" {
+		for i := range findings {
+			findings[i].File = source
+		}
+	}
+	if mode == RedactAbort {
+		return "", &SecretsFoundError{Findings: findings}
+	}
+
+	fmt.Fprintf(cli.root.ErrOrStderr(), "Masked %d possible secrets before sending changes to the model:\n", len(findings))
+	for _, finding := range findings {
+		fmt.Fprintf(cli.root.ErrOrStderr(), "  %s\n", finding)
+	}
+	return redacted, nil
+}
+
+// prepareDiff readies a diff for a prompt: ignored files are dropped, secrets redacted,
+// and the rest summarized if it exceeds the token budget
+func (cli *Cli) prepareDiff(model Model, diff string, spinner bool) (string, bool, error) {
+	diff, err := cli.filterDiff(diff)
+	if err != nil {
+		return "", false, err
+	}
+	diff, err = cli.redactDiff(diff)
+	if err != nil {
+		return "", false, err
+	}
+	return cli.fitDiff(model, diff, spinner)
+}
+
+// fitDiff summarizes a diff that exceeds the configured token budget, so it fits in a single
+// prompt. The spinner is shown while summarizing unless the output must stay machine readable.
+func (cli *Cli) fitDiff(model Model, diff string, spinner bool) (string, bool, error) {
+	summarizer := &DiffSummarizer{
+		Model:       model,
+		Prompts:     cli.prompts(),
+		Budget:      cli.config.Int("diff.max_tokens"),
+		Parallelism: cli.config.Int("diff.parallelism"),
+		Log:         cli.verbosef,
+		Quiet:       !spinner,
+	}
+	return summarizer.Summarize(context.Background(), diff)
+}
+
+// modelOptions resolves the model settings for a config section such as "commit" or "pr"
+func (cli *Cli) modelOptions(section string) ModelOptions {
+	name := cli.config.Get(section + ".model")
+	if name == "" {
+		name = cli.config.Get("model.name")
+	}
+	endpoints := map[string]string{}
+	for provider, key := range endpointSettings {
+		endpoints[provider] = cli.config.Get(key)
+	}
+	return ModelOptions{
+		Provider:    cli.config.Get("model.provider"),
+		Name:        name,
+		MaxTokens:   cli.config.Int(section + ".max_tokens"),
+		Temperature: cli.config.Float(section + ".temperature"),
+		Endpoints:   endpoints,
+	}
+}
+
+// endpointSettings maps model providers to the config key holding their server URL
+var endpointSettings = map[string]string{
+	"openai":   "openai.base_url",
+	"ollama":   "ollama.host",
+	"llamacpp": "llamacpp.host",
+}
+
 // cleanMarkdownCodeBlocks removes markdown code block markers and AI prefixes from the text
 func cleanMarkdownCodeBlocks(text string) string {
 	// Remove ``` at the start and end of the text
@@ -87,6 +352,221 @@ func cleanMarkdownCodeBlocks(text string) string {
 	return strings.Join(uniqueLines, "\n")
 }
 
+// trimCodeFence removes a markdown code fence and AI prefix around text, leaving its lines
+// untouched so that paragraphs, lists and repeated lines survive
+func trimCodeFence(text string) string {
+	text = strings.TrimSpace(text)
+	if len(text) >= 6 && strings.HasPrefix(text, "```") && strings.HasSuffix(text, "```") {
+		text = strings.TrimSuffix(text, "```")
+		// The opening fence may name a language, as in ```markdown
+		if i := strings.Index(text, "\n"); i >= 0 {
+			text = text[i+1:]
+		} else {
+			text = text[3:]
+		}
+	}
+	text = strings.TrimSpace(text)
+	text = strings.TrimPrefix(text, "AI:")
+	return strings.TrimSpace(text)
+}
+
+// queryWithSpinner queries the model while showing a spinner. Models that support
+// streaming have their answer rendered live as it is generated.
+func queryWithSpinner(model Model, message, query string) (string, error) {
+	var answer string
+	if streaming, ok := model.(StreamingModel); ok {
+		err := WithStreamingSpinner(message, func(onDelta func(string)) error {
+			var err error
+			answer, err = streaming.QueryStream(context.Background(), query, onDelta)
+			return err
+		})
+		return answer, err
+	}
+	err := WithSpinner(message, func() error {
+		var err error
+		answer, err = model.Query(context.Background(), query)
+		return err
+	})
+	return answer, err
+}
+
+// prompts returns the prompt loader for the configured template directory
+func (cli *Cli) prompts() *Prompts {
+	dir := cli.config.Get("prompts.dir")
+	if dir != "" && !filepath.IsAbs(dir) {
+		root, err := RepoRoot()
+		if err != nil {
+			// Outside a repository only the built-in prompts are available
+			return NewPrompts("")
+		}
+		dir = filepath.Join(root, dir)
+	}
+	return NewPrompts(dir)
+}
+
+// promptData collects the prompt variables describing the given branch
+func (cli *Cli) promptData(branch string) PromptData {
+	branch = strings.TrimSpace(branch)
+	data := PromptData{
+		Branch: branch,
+		Ticket: extractTicket(branch, cli.config.Get("prompts.ticket_pattern")),
+	}
+	// Recent commits are optional context, a repository without commits has none
+	if n := cli.config.Int("prompts.recent_commits"); n > 0 {
+		data.RecentCommits, _ = cli.git.GetRecentCommits(n)
+	}
+	return data
+}
+
+// commitPromptData collects the prompt variables for a commit of the given staged diff.
+// Everything except the diff is best effort, since it only enriches the prompt.
+func (cli *Cli) commitPromptData(diff string) PromptData {
+	branch, _ := cli.git.GetCurrentBranch()
+	data := cli.promptData(branch)
+	data.Diff = diff
+	data.Files, _ = cli.git.GetStagedFiles()
+	return data
+}
+
+// generateCommitMessage asks the model for a commit message and, unless --yes is given,
+// lets the user accept, edit or regenerate it
+func (cli *Cli) generateCommitMessage(cmd *cobra.Command, model Model, query string) (string, error) {
+	// Nothing is committed in a dry run, so there is nothing to review
+	yes, _ := cmd.Flags().GetBool("yes")
+	yes = yes || isDryRun(cmd)
+	if n := cli.config.Int("commit.candidates"); n > 1 && !yes {
+		return cli.pickCommitMessage(model, query, n)
+	}
+
+	message, err := queryWithSpinner(model, "Generating commit message...", query)
+	if err != nil {
+		return "", fmt.Errorf("error getting commit message from AI: %w", err)
+	}
+
+	// Clean up the message
+	message = cleanMarkdownCodeBlocks(message)
+
+	if yes {
+		return message, nil
+	}
+
+	for {
+		result, err := cli.prompter.Review(message)
+		if err != nil {
+			return "", err
+		}
+
+		switch result.Action {
+		case ReviewAccept:
+			return message, nil
+
+		case ReviewEdit:
+			message, err = cli.prompter.Edit(message)
+			if err != nil {
+				return "", err
+			}
+
+		case ReviewRegenerate:
+			guided := query
+			if result.Guidance != "" {
+				guided += "\n\nAdditional guidance for the message:\n" + result.Guidance
+			}
+			message, err = queryWithSpinner(model, "Regenerating commit message...", guided)
+			if err != nil {
+				return "", fmt.Errorf("error getting commit message from AI: %w", err)
+			}
+			message = cleanMarkdownCodeBlocks(message)
+
+		default:
+			return "", ErrAborted
+		}
+	}
+}
+
+// candidateSeparator separates alternative messages in a single model answer
+const candidateSeparator = "---"
+
+// pickCommitMessage asks the model for n distinct commit messages and lets the user choose one
+func (cli *Cli) pickCommitMessage(model Model, query string, n int) (string, error) {
+	candidates, err := generateCandidates(model, query, n)
+	if err != nil {
+		return "", err
+	}
+	index, err := cli.prompter.Pick(candidates)
+	if err != nil {
+		return "", err
+	}
+	return candidates[index], nil
+}
+
+// generateCandidates asks the model for n distinct commit messages
+func generateCandidates(model Model, query string, n int) ([]string, error) {
+	query += fmt.Sprintf("\n\nWrite %d distinct alternative commit messages, varying the scope and level of detail. "+
+		"Separate the alternatives with a line containing only %s and do not number them.", n, candidateSeparator)
+	answer, err := queryWithSpinner(model, fmt.Sprintf("Generating %d commit messages...", n), query)
+	if err != nil {
+		return nil, fmt.Errorf("error getting commit messages from AI: %w", err)
+	}
+	candidates := splitCandidates(answer)
+	if len(candidates) == 0 {
+		return nil, fmt.Errorf("AI returned no commit messages")
+	}
+	return candidates, nil
+}
+
+// dryRunCommitMessages generates the commit messages of a dry run. Nobody picks between
+// candidates in a dry run, so all of them are returned.
+func (cli *Cli) dryRunCommitMessages(cmd *cobra.Command, model Model, query string) ([]string, error) {
+	if n := cli.config.Int("commit.candidates"); n > 1 {
+		return generateCandidates(model, query, n)
+	}
+	message, err := cli.generateCommitMessage(cmd, model, query)
+	if err != nil {
+		return nil, err
+	}
+	return []string{message}, nil
+}
+
+// printDryRunCommits prints the generated commit messages of a dry run with the commands
+// each of them would run
+func printDryRunCommits(cmd *cobra.Command, messages []string, commands func(message string) []string) {
+	for i, message := range messages {
+		if len(messages) == 1 {
+			fmt.Fprintf(cmd.OutOrStdout(), "Generated commit message:\n%s\n", message)
+		} else {
+			if i > 0 {
+				fmt.Fprintln(cmd.OutOrStdout())
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "Generated commit message %d of %d:\n%s\n", i+1, len(messages), message)
+		}
+		printDryRun(cmd, commands(message)...)
+	}
+}
+
+// splitCandidates splits an answer holding several messages into cleaned, distinct messages
+func splitCandidates(answer string) []string {
+	var candidates []string
+	seen := map[string]bool{}
+	var current []string
+	flush := func() {
+		candidate := cleanMarkdownCodeBlocks(strings.Join(current, "\n"))
+		if candidate != "" && !seen[candidate] {
+			seen[candidate] = true
+			candidates = append(candidates, candidate)
+		}
+		current = nil
+	}
+	for _, line := range strings.Split(answer, "\n") {
+		if strings.TrimSpace(line) == candidateSeparator {
+			flush()
+			continue
+		}
+		current = append(current, line)
+	}
+	flush()
+	return candidates
+}
+
 func (cli *Cli) commit(cmd *cobra.Command, args []string) error {
 	// Get staged changes
 	diff, err := cli.git.GetStagedDiff()
@@ -98,20 +578,36 @@ func (cli *Cli) commit(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("no changes staged for commit")
 	}
 
+	model, err := cli.model(cmd, "commit")
+	if err != nil {
+		return err
+	}
+
 	// Ask AI for commit message
-	var message string
-	err = WithSpinner("Generating commit message...", func() error {
-		query := fmt.Sprintf("Please write a concise and descriptive commit message, adhering to conventional commits and in plain text, for the following changes:\n\n%s", diff)
-		var err error
-		message, err = cli.model.Query(context.Background(), query)
+	data := cli.commitPromptData(diff)
+	data.Diff, data.Summarized, err = cli.prepareDiff(model, diff, true)
+	if err != nil {
 		return err
-	})
+	}
+	query, err := cli.prompts().Render(PromptCommit, data)
 	if err != nil {
-		return fmt.Errorf("error getting commit message from AI: %w", err)
+		return err
 	}
 
-	// Clean up the message
-	message = cleanMarkdownCodeBlocks(message)
+	if isDryRun(cmd) {
+		messages, err := cli.dryRunCommitMessages(cmd, model, query)
+		if err != nil {
+			return err
+		}
+		printDryRunCommits(cmd, messages, func(message string) []string {
+			return []string{formatCommand("git", commitArgs(message)...)}
+		})
+		return nil
+	}
+	message, err := cli.generateCommitMessage(cmd, model, query)
+	if err != nil {
+		return err
+	}
 
 	// Execute git commit
 	if err := cli.git.Commit(message); err != nil {
@@ -133,20 +629,40 @@ func (cli *Cli) amend(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("no changes staged for amend")
 	}
 
+	model, err := cli.model(cmd, "commit")
+	if err != nil {
+		return err
+	}
+
 	// Ask AI for commit message
-	var message string
-	err = WithSpinner("Generating commit message...", func() error {
-		query := fmt.Sprintf("Please write a concise and descriptive commit message, adhering to conventional commits and in plain text, for the following changes:\n\n%s", diff)
-		var err error
-		message, err = cli.model.Query(context.Background(), query)
+	data := cli.commitPromptData(diff)
+	data.Diff, data.Summarized, err = cli.prepareDiff(model, diff, true)
+	if err != nil {
 		return err
-	})
+	}
+	query, err := cli.prompts().Render(PromptAmend, data)
 	if err != nil {
-		return fmt.Errorf("error getting commit message from AI: %w", err)
+		return err
 	}
 
-	// Clean up the message
-	message = cleanMarkdownCodeBlocks(message)
+	if isDryRun(cmd) {
+		messages, err := cli.dryRunCommitMessages(cmd, model, query)
+		if err != nil {
+			return err
+		}
+		printDryRunCommits(cmd, messages, func(message string) []string {
+			var commands []string
+			for _, args := range amendArgs(message) {
+				commands = append(commands, formatCommand("git", args...))
+			}
+			return commands
+		})
+		return nil
+	}
+	message, err := cli.generateCommitMessage(cmd, model, query)
+	if err != nil {
+		return err
+	}
 
 	// Execute git amend
 	if err := cli.git.Amend(message); err != nil {
@@ -157,6 +673,260 @@ func (cli *Cli) amend(cmd *cobra.Command, args []string) error {
 	return nil
 }
 
+// pushBranch pushes the current branch. If the push is rejected because the remote has
+// diverged, it force pushes with a lease on the last fetched remote commit, after
+// confirmation or when --force-with-lease is given. Other failures are returned as is.
+func (cli *Cli) pushBranch(cmd *cobra.Command) error {
+	err := cli.git.Push()
+	if err == nil {
+		fmt.Println("Branch pushed successfully")
+		return nil
+	}
+
+	var pushErr *PushError
+	if !errors.As(err, &pushErr) || pushErr.Reason != PushRejected {
+		return fmt.Errorf("failed to push branch: %w", err)
+	}
+
+	expected, err := cli.git.GetRemoteBranchSHA()
+	if err != nil {
+		return fmt.Errorf("push was rejected and the remote branch is unknown: %w", err)
+	}
+
+	force, _ := cmd.Flags().GetBool("force-with-lease")
+	if !force {
+		question := fmt.Sprintf("Push was rejected because the remote branch has diverged. Force push, overwriting the remote branch only if it is still at %s?", shortSHA(expected))
+		force, err = cli.prompter.Confirm(question)
+		if errors.Is(err, ErrNotInteractive) {
+			return fmt.Errorf("push was rejected, use --force-with-lease to force push: %w", pushErr)
+		}
+		if err != nil {
+			return err
+		}
+		if !force {
+			return fmt.Errorf("push was rejected and force push was declined: %w", pushErr)
+		}
+	}
+
+	fmt.Printf("Force pushing with lease on %s...\n", shortSHA(expected))
+	if err := cli.git.ForcePushWithLease(expected); err != nil {
+		return fmt.Errorf("failed to push branch: %w", err)
+	}
+	fmt.Println("Force push successful")
+	return nil
+}
+
+// pushCommand returns the git command pushBranch would end up running, for dry runs. A branch
+// missing on the remote gets its upstream set, and one that has diverged from it is force pushed
+// with a lease, after confirmation unless --force-with-lease is given.
+func (cli *Cli) pushCommand(cmd *cobra.Command) (string, error) {
+	branch, err := cli.git.GetCurrentBranch()
+	if err != nil {
+		return "", fmt.Errorf("could not get current branch: %w", err)
+	}
+	branch = strings.TrimSpace(branch)
+	expected, err := cli.git.GetRemoteBranchSHA()
+	if err != nil {
+		return formatCommand("git", "push", "--set-upstream", "origin", branch), nil
+	}
+	ancestor, err := cli.git.IsAncestor(expected)
+	if err != nil {
+		return "", err
+	}
+	if ancestor {
+		return formatCommand("git", pushArgs()...), nil
+	}
+	command := formatCommand("git", forcePushArgs(branch, expected)...)
+	if force, _ := cmd.Flags().GetBool("force-with-lease"); !force {
+		command += " (after confirmation)"
+	}
+	return command, nil
+}
+
+// shortSHA abbreviates a commit hash for display
+func shortSHA(sha string) string {
+	if len(sha) > 12 {
+		return sha[:12]
+	}
+	return sha
+}
+
+// baseBranch picks the base of the pull request: the --base flag or pr.base setting first,
+// then the base of an already open pull request, then the base detected by git
+func (cli *Cli) baseBranch() (string, error) {
+	if base := cli.config.Get("pr.base"); base != "" {
+		return base, nil
+	}
+	base, err := cli.forge.GetPullRequestBase()
+	if err != nil {
+		cli.verbosef("Could not look up the base of an open pull request: %v", err)
+	} else if base != "" {
+		return base, nil
+	}
+	return cli.git.GetBaseBranch()
+}
+
+// prTemplate returns the path of the pull request template to follow, or an empty string if there
+// is none. The pr.template setting names a template, otherwise the default template is used, or
+// one picked from the PULL_REQUEST_TEMPLATE directory. Templates are sent to the model, so apart
+// from absolute paths set by the user they must be inside the repository.
+func (cli *Cli) prTemplate() (string, error) {
+	setting := cli.config.Get("pr.template")
+	if setting == PRTemplateNone {
+		return "", nil
+	}
+	if setting != "" && filepath.IsAbs(setting) {
+		if cli.config.FromRepoConfig("pr.template") {
+			return "", fmt.Errorf("pull request template %s: %s may only name templates inside the repository", setting, RepoConfigFile)
+		}
+		return setting, nil
+	}
+	root, err := RepoRoot()
+	if err != nil {
+		if setting != "" {
+			return "", fmt.Errorf("pull request template %s: %w", setting, err)
+		}
+		return "", nil
+	}
+	path, err := cli.pickPRTemplate(root, setting)
+	if err != nil || path == "" {
+		return path, err
+	}
+	if err := checkInsideDir(root, path); err != nil {
+		return "", fmt.Errorf("pull request template: %w", err)
+	}
+	return path, nil
+}
+
+// pickPRTemplate finds the template named by setting in the repository at root, or picks one of
+// its templates when setting is empty
+func (cli *Cli) pickPRTemplate(root, setting string) (string, error) {
+	defaultTemplate, templates, err := FindPRTemplates(root)
+	if err != nil {
+		return "", err
+	}
+
+	if setting != "" {
+		// A template in a PULL_REQUEST_TEMPLATE directory can be named by its file name
+		for _, path := range templates {
+			if filepath.Base(path) == setting {
+				return path, nil
+			}
+		}
+		return filepath.Join(root, setting), nil
+	}
+	if defaultTemplate != "" || len(templates) == 0 {
+		return defaultTemplate, nil
+	}
+	if len(templates) == 1 {
+		return templates[0], nil
+	}
+
+	names := make([]string, len(templates))
+	for i, path := range templates {
+		names[i], _ = filepath.Rel(root, path)
+	}
+	picked, err := cli.prompter.Pick(names)
+	if errors.Is(err, ErrNotInteractive) {
+		cli.verbosef("Several pull request templates found, using %s. Set pr.template to choose another", names[0])
+		return templates[0], nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return templates[picked], nil
+}
+
+// generateDescription asks the model for a pull request description. When following a template,
+// descriptions missing any of its headings are regenerated with the missing headings pointed out.
+func (cli *Cli) generateDescription(model Model, query, templatePath, template string) (string, error) {
+	headings := templateHeadings(template)
+	guided := query
+	for attempt := 0; ; attempt++ {
+		description, err := queryWithSpinner(model, "Generating pull request description...", guided)
+		if err != nil {
+			return "", fmt.Errorf("error getting PR content from AI: %w", err)
+		}
+
+		// Clean up the description
+		description = trimCodeFence(description)
+
+		missing := missingHeadings(headings, description)
+		if len(missing) == 0 {
+			return description, nil
+		}
+		if attempt == maxTemplateRetries {
+			return "", &MissingSectionsError{Template: templatePath, Missing: missing}
+		}
+		cli.verbosef("Description is missing sections %s, regenerating", strings.Join(missing, ", "))
+		guided = query + "\n\nThe description must keep every heading of the template. These headings were missing:\n" +
+			strings.Join(missing, "\n")
+	}
+}
+
+// pullRequest assembles a pull request from the generated content and the pr settings.
+// An open pull request keeps its base unless one is set explicitly.
+func (cli *Cli) pullRequest(title, description, base, diff string, editing bool) (PullRequest, error) {
+	if editing {
+		base = cli.config.Get("pr.base")
+	}
+	pr := PullRequest{
+		Title:         title,
+		Description:   description,
+		Base:          base,
+		Draft:         cli.config.Bool("pr.draft"),
+		Reviewers:     cli.config.List("pr.reviewers"),
+		TeamReviewers: cli.config.List("pr.team_reviewers"),
+		Labels:        cli.config.List("pr.labels"),
+		Assignees:     cli.config.List("pr.assignees"),
+		Milestone:     cli.config.Get("pr.milestone"),
+	}
+	if !cli.config.Bool("pr.suggest_reviewers") {
+		return pr, nil
+	}
+
+	root, err := RepoRoot()
+	if err != nil {
+		return pr, fmt.Errorf("error looking for CODEOWNERS: %w", err)
+	}
+	owners, err := LoadCodeOwners(root)
+	if err != nil || owners == nil {
+		cli.verbosef("No CODEOWNERS to suggest reviewers from")
+		return pr, err
+	}
+	var paths []string
+	for _, file := range parseDiff(diff) {
+		if file.Path != "" {
+			paths = append(paths, file.Path)
+		}
+	}
+	reviewers, teams := owners.SuggestReviewers(paths)
+	if len(reviewers) > 0 {
+		// Forges refuse to request a review from the author of a pull request
+		author, err := cli.forge.GetCurrentUser()
+		if err != nil {
+			return pr, err
+		}
+		reviewers = slices.DeleteFunc(reviewers, func(reviewer string) bool {
+			return strings.EqualFold(reviewer, author)
+		})
+	}
+	cli.verbosef("Suggested reviewers from CODEOWNERS: %s", strings.Join(append(append([]string{}, reviewers...), teams...), ", "))
+	pr.Reviewers = appendMissing(pr.Reviewers, reviewers...)
+	pr.TeamReviewers = appendMissing(pr.TeamReviewers, teams...)
+	return pr, nil
+}
+
+// appendMissing appends the values not already in list
+func appendMissing(list []string, values ...string) []string {
+	for _, value := range values {
+		if !slices.Contains(list, value) {
+			list = append(list, value)
+		}
+	}
+	return list
+}
+
 func (cli *Cli) createPR(cmd *cobra.Command, args []string) error {
 	// Get current branch
 	currentBranch, err := cli.git.GetCurrentBranch()
@@ -164,11 +934,11 @@ func (cli *Cli) createPR(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("error getting current branch: %w", err)
 	}
 
-	// Get base branch
-	baseBranch, err := cli.git.GetBaseBranch()
+	baseBranch, err := cli.baseBranch()
 	if err != nil {
 		return fmt.Errorf("error getting base branch: %w", err)
 	}
+	cli.verbosef("Using base branch %s", baseBranch)
 
 	// Get commit history
 	history, err := cli.git.GetCommitHistory(baseBranch)
@@ -180,59 +950,94 @@ func (cli *Cli) createPR(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("no commits found between %s and %s", baseBranch, currentBranch)
 	}
 
-	// Try to push the branch
-	if err := cli.git.Push(); err != nil {
-		fmt.Println("Regular push failed, attempting force push...")
-		if err := cli.git.ForcePush(); err != nil {
-			return fmt.Errorf("failed to push branch: %w", err)
-		}
-		fmt.Println("Force push successful")
-	} else {
-		fmt.Println("Branch pushed successfully")
+	model, err := cli.model(cmd, "pr")
+	if err != nil {
+		return err
 	}
 
-	// Ask AI for PR description and title
-	var description, title string
-	err = WithSpinner("Generating pull request description...", func() error {
-		query := fmt.Sprintf("Please write a concise and descriptive pull request description for the following changes. Include a summary of the changes and any important notes for reviewers:\n\n%s", history)
-		var err error
-		description, err = cli.model.Query(context.Background(), query)
-		if err != nil {
-			return err
-		}
-
-		// Clean up the description
-		description = cleanMarkdownCodeBlocks(description)
+	// The diff of the whole branch shows what the commits really change
+	diff, err := cli.git.GetBranchDiff(baseBranch)
+	if err != nil {
+		return fmt.Errorf("error getting branch diff: %w", err)
+	}
 
-		// Ask AI to generate a clean title based on the description
-		titleQuery := fmt.Sprintf("Based on this pull request description, generate a concise, descriptive title (max 72 chars) that follows conventional commits format. Return only the title, no markdown or quotes:\n\n%s", description)
-		title, err = cli.model.Query(context.Background(), titleQuery)
-		if err != nil {
+	// Ask AI for PR description
+	data := cli.promptData(currentBranch)
+	data.Stats = diffStat(diff)
+	if data.History, err = cli.redactHistory(history); err != nil {
+		return err
+	}
+	data.Diff, data.Summarized, err = cli.prepareDiff(model, diff, true)
+	if err != nil {
+		return err
+	}
+	templatePath, err := cli.prTemplate()
+	if err != nil {
+		return err
+	}
+	if templatePath != "" {
+		cli.verbosef("Following pull request template %s", templatePath)
+		if data.Template, err = readPRTemplate(templatePath); err != nil {
 			return err
 		}
+	}
+	query, err := cli.prompts().Render(PromptPR, data)
+	if err != nil {
+		return err
+	}
+	description, err := cli.generateDescription(model, query, templatePath, data.Template)
+	if err != nil {
+		return err
+	}
 
-		// Clean up the title
-		title = cleanMarkdownCodeBlocks(title)
-		title = strings.TrimSpace(title)
-		return nil
-	})
+	// Ask AI to generate a clean title based on the description
+	data.Description = description
+	titleQuery, err := cli.prompts().Render(PromptPRTitle, data)
+	if err != nil {
+		return err
+	}
+	title, err := queryWithSpinner(model, "Generating pull request title...", titleQuery)
 	if err != nil {
 		return fmt.Errorf("error getting PR content from AI: %w", err)
 	}
 
+	// Clean up the title
+	title = cleanMarkdownCodeBlocks(title)
+	title = strings.TrimSpace(title)
+
 	// Check if a PR already exists for this branch
-	hasPR, err := cli.github.HasOpenPullRequest()
+	hasPR, err := cli.forge.HasOpenPullRequest()
 	if err != nil {
 		return fmt.Errorf("error checking for existing pull request: %w", err)
 	}
 
+	pr, err := cli.pullRequest(title, description, baseBranch, diff, hasPR)
+	if err != nil {
+		return err
+	}
+
+	if isDryRun(cmd) {
+		fmt.Fprintf(cmd.OutOrStdout(), "Generated pull request title:\n%s\n\nGenerated pull request description:\n%s\n", title, description)
+		push, err := cli.pushCommand(cmd)
+		if err != nil {
+			return err
+		}
+		printDryRun(cmd, push, cli.forge.DescribePullRequest(pr, hasPR))
+		return nil
+	}
+
+	// Push only once the pull request is ready, so that a failure above leaves nothing behind
+	if err := cli.pushBranch(cmd); err != nil {
+		return err
+	}
+
 	if hasPR {
-		if err := cli.github.EditPullRequest(title, description); err != nil {
+		if err := cli.forge.EditPullRequest(pr); err != nil {
 			return fmt.Errorf("error updating pull request: %w", err)
 		}
 		fmt.Printf("Updated pull request with title:\n%s\n", title)
 	} else {
-		if err := cli.github.CreatePullRequest(title, description); err != nil {
+		if err := cli.forge.CreatePullRequest(pr); err != nil {
 			return fmt.Errorf("error creating pull request: %w", err)
 		}
 		fmt.Printf("Created pull request with title:\n%s\n", title)
@@ -0,0 +1,102 @@
+package aigit
+
+import (
+	"fmt"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+)
+
+func (cli *Cli) configCommand() *cobra.Command {
+	configCmd := &cobra.Command{
+		Use:   "config",
+		Short: "Inspect and change aigit settings",
+		Long: `Inspect and change aigit settings.
+
+Settings are merged from ~/.config/aigit/config.yaml, the repository's .aigit.yaml,
+AIGIT_* environment variables and command line flags, in increasing order of precedence.`,
+	}
+
+	listCmd := &cobra.Command{
+		Use:   "list",
+		Short: "List all settings with their effective values and sources",
+		Args:  cobra.NoArgs,
+		RunE:  cli.configList,
+	}
+
+	getCmd := &cobra.Command{
+		Use:   "get <key>",
+		Short: "Print the effective value of a setting",
+		Args:  cobra.ExactArgs(1),
+		RunE:  cli.configGet,
+	}
+	getCmd.Flags().Bool("source", false, "also print where the value came from")
+
+	setCmd := &cobra.Command{
+		Use:   "set <key> <value>",
+		Short: "Store a setting in the repository config file",
+		Args:  cobra.ExactArgs(2),
+		RunE:  cli.configSet,
+	}
+	setCmd.Flags().Bool("global", false, "store the setting in the user config file instead")
+
+	configCmd.AddCommand(listCmd)
+	configCmd.AddCommand(getCmd)
+	configCmd.AddCommand(setCmd)
+	return configCmd
+}
+
+func (cli *Cli) configList(cmd *cobra.Command, args []string) error {
+	w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "KEY\tVALUE\tSOURCE")
+	for _, key := range cli.config.Keys() {
+		fmt.Fprintf(w, "%s\t%s\t%s\n", key, cli.displayValue(key), cli.config.Source(key))
+	}
+	return w.Flush()
+}
+
+func (cli *Cli) configGet(cmd *cobra.Command, args []string) error {
+	key := args[0]
+	if _, err := LookupSetting(key); err != nil {
+		return err
+	}
+
+	showSource, _ := cmd.Flags().GetBool("source")
+	if showSource {
+		fmt.Fprintf(cmd.OutOrStdout(), "%s\t(%s)\n", cli.displayValue(key), cli.config.Source(key))
+		return nil
+	}
+	fmt.Fprintln(cmd.OutOrStdout(), cli.displayValue(key))
+	return nil
+}
+
+// displayValue returns the effective value of a setting for printing, with secrets masked
+func (cli *Cli) displayValue(key string) string {
+	value := cli.config.Get(key)
+	if s, err := LookupSetting(key); err == nil && s.Secret && value != "" {
+		return "********"
+	}
+	return value
+}
+
+func (cli *Cli) configSet(cmd *cobra.Command, args []string) error {
+	key, value := args[0], args[1]
+
+	global, _ := cmd.Flags().GetBool("global")
+	var path string
+	var err error
+	if global {
+		path, err = UserConfigPath()
+	} else {
+		path, err = RepoConfigPath()
+	}
+	if err != nil {
+		return fmt.Errorf("error locating config file: %w", err)
+	}
+
+	if err := WriteConfigFile(path, key, value); err != nil {
+		return err
+	}
+	fmt.Fprintf(cmd.OutOrStdout(), "Set %s = %s in %s\n", key, value, path)
+	return nil
+}
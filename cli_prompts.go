@@ -0,0 +1,51 @@
+package aigit
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+)
+
+func (cli *Cli) promptsCommand() *cobra.Command {
+	promptsCmd := &cobra.Command{
+		Use:   "prompts",
+		Short: "Inspect the prompt templates",
+		Long: `Inspect the prompt templates used to generate messages.
+
+Templates use Go text/template syntax. Override a prompt by placing <name>.tmpl in the
+directory set by prompts.dir (default .aigit/prompts in the repository root).
+
+Available variables: .Diff, .Summarized, .Stats, .History, .Description, .Template, .Branch,
+.Ticket, .Files and .RecentCommits.`,
+	}
+
+	showCmd := &cobra.Command{
+		Use:   "show [name]",
+		Short: "Print the effective prompt templates",
+		Args:  cobra.MaximumNArgs(1),
+		RunE:  cli.promptsShow,
+	}
+
+	promptsCmd.AddCommand(showCmd)
+	return promptsCmd
+}
+
+func (cli *Cli) promptsShow(cmd *cobra.Command, args []string) error {
+	names := PromptNames()
+	if len(args) > 0 {
+		names = args
+	}
+
+	prompts := cli.prompts()
+	for i, name := range names {
+		text, source, err := prompts.Source(name)
+		if err != nil {
+			return err
+		}
+		if i > 0 {
+			fmt.Fprintln(cmd.OutOrStdout())
+		}
+		fmt.Fprintf(cmd.OutOrStdout(), "# %s (%s)\n%s\n", name, source, text)
+	}
+	return nil
+}
@@ -0,0 +1,201 @@
+package aigit
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+func (cli *Cli) reviewCommand() *cobra.Command {
+	reviewCmd := &cobra.Command{
+		Use:   "review",
+		Short: "Review staged changes or the current branch with AI",
+		Long: `Review the staged changes, or with --branch the changes of the current branch since it
+left its base branch, and print the findings with their file, line range and severity.
+
+The command fails when a finding is at or above the severity set by review.fail_on
+(default error), so it can run in a pre-push hook.`,
+		Args:         cobra.NoArgs,
+		SilenceUsage: true,
+		PreRunE:      cli.requireGit,
+		RunE:         cli.review,
+	}
+	addModelFlags(reviewCmd, "review")
+	reviewCmd.Flags().Bool("branch", false, "review the changes of the current branch instead of the staged changes")
+	reviewCmd.Flags().String("base", "", "base branch to review the current branch against, implies --branch")
+	reviewCmd.Flags().String("format", "", "output format, text or json")
+	bindFlag(reviewCmd, "format", "review.format")
+	reviewCmd.Flags().String("fail-on", "", "lowest severity that fails the review: info, warning, error or none")
+	bindFlag(reviewCmd, "fail-on", "review.fail_on")
+	return reviewCmd
+}
+
+func (cli *Cli) review(cmd *cobra.Command, args []string) error {
+	data, err := cli.reviewPromptData(cmd)
+	if err != nil {
+		return err
+	}
+	if data.Diff == "" {
+		return fmt.Errorf("no changes to review")
+	}
+
+	model, err := cli.model(cmd, "review")
+	if err != nil {
+		return err
+	}
+	format := cli.config.Get("review.format")
+	// Keep the spinner out of output meant for other programs
+	findings, err := cli.queryFindings(model, data, format != ReviewFormatJSON)
+	if err != nil {
+		return err
+	}
+
+	if err := writeFindings(cmd.OutOrStdout(), findings, format); err != nil {
+		return err
+	}
+	failOn := cli.config.Get("review.fail_on")
+	if n := countFindings(findings, failOn); n > 0 {
+		return &ReviewFailedError{Severity: failOn, Count: n}
+	}
+	return nil
+}
+
+func (cli *Cli) prReviewCommand() *cobra.Command {
+	reviewCmd := &cobra.Command{
+		Use:   "review",
+		Short: "Review the open pull request of the current branch with AI",
+		Long: `Review the diff of the open pull request of the current branch and add the findings as
+a pending review with inline comments. The review stays pending, visible only to you, until
+you submit it on GitHub.`,
+		Args:    cobra.NoArgs,
+		PreRunE: cli.requireForge,
+		RunE:    cli.reviewPR,
+	}
+	addModelFlags(reviewCmd, "review")
+	addDryRunFlag(reviewCmd)
+	return reviewCmd
+}
+
+func (cli *Cli) reviewPR(cmd *cobra.Command, args []string) error {
+	reviewer, ok := cli.forge.(PullRequestReviewer)
+	if !ok {
+		return ErrReviewsUnsupported
+	}
+	hasPR, err := cli.forge.HasOpenPullRequest()
+	if err != nil {
+		return fmt.Errorf("error checking for existing pull request: %w", err)
+	}
+	if !hasPR {
+		return ErrNoPullRequest
+	}
+
+	diff, commit, err := reviewer.GetPullRequestDiff()
+	if err != nil {
+		return err
+	}
+	if diff == "" {
+		return fmt.Errorf("the pull request has no changes to review")
+	}
+
+	model, err := cli.model(cmd, "review")
+	if err != nil {
+		return err
+	}
+	branch, _ := cli.git.GetCurrentBranch()
+	data := cli.promptData(branch)
+	data.Diff = diff
+	data.Stats = diffStat(diff)
+	findings, err := cli.queryFindings(model, data, true)
+	if err != nil {
+		return err
+	}
+	// Positions refer to the diff as the forge shows it, before any filtering
+	review := newReview(findings, diff, commit)
+
+	if isDryRun(cmd) {
+		payload, err := json.MarshalIndent(review, "", "  ")
+		if err != nil {
+			return fmt.Errorf("error encoding review: %w", err)
+		}
+		fmt.Fprintf(cmd.OutOrStdout(), "Dry run, would create this pending review:\n%s\n", payload)
+		return nil
+	}
+	if len(findings) == 0 {
+		fmt.Fprintln(cmd.OutOrStdout(), "No issues found, no review created.")
+		return nil
+	}
+	if err := reviewer.CreateReview(review); err != nil {
+		return err
+	}
+	fmt.Fprintf(cmd.OutOrStdout(), "Added a pending review with %d comments, submit it to publish them.\n", len(review.Comments))
+	return nil
+}
+
+// queryFindings asks the model to review the diff in data, numbering its lines so that
+// findings can refer to them
+func (cli *Cli) queryFindings(model Model, data PromptData, spinner bool) ([]Finding, error) {
+	var err error
+	data.Diff, data.Summarized, err = cli.prepareDiff(model, data.Diff, spinner)
+	if err != nil {
+		return nil, err
+	}
+	if data.History, err = cli.redactHistory(data.History); err != nil {
+		return nil, err
+	}
+	if !data.Summarized {
+		data.Diff = numberDiffLines(data.Diff)
+	}
+	query, err := cli.prompts().Render(PromptReview, data)
+	if err != nil {
+		return nil, err
+	}
+
+	var answer string
+	if spinner {
+		answer, err = queryWithSpinner(model, "Reviewing changes...", query)
+	} else {
+		answer, err = model.Query(context.Background(), query)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reviewing changes: %w", err)
+	}
+	return ParseFindings(answer)
+}
+
+// reviewPromptData collects the diff to review with its context: the staged changes, or the
+// changes and commits of the current branch when --branch or --base is given
+func (cli *Cli) reviewPromptData(cmd *cobra.Command) (PromptData, error) {
+	base, _ := cmd.Flags().GetString("base")
+	branch, _ := cmd.Flags().GetBool("branch")
+	if base == "" && !branch {
+		diff, err := cli.git.GetStagedDiff()
+		if err != nil {
+			return PromptData{}, fmt.Errorf("error getting staged changes: %w", err)
+		}
+		return cli.commitPromptData(diff), nil
+	}
+
+	if base == "" {
+		var err error
+		if base, err = cli.git.GetBaseBranch(); err != nil {
+			return PromptData{}, fmt.Errorf("error detecting base branch: %w", err)
+		}
+		base = strings.TrimSpace(base)
+	}
+	current, err := cli.git.GetCurrentBranch()
+	if err != nil {
+		return PromptData{}, fmt.Errorf("error getting current branch: %w", err)
+	}
+	data := cli.promptData(current)
+	if data.History, err = cli.git.GetCommitHistory(base); err != nil {
+		return PromptData{}, fmt.Errorf("error getting commit history: %w", err)
+	}
+	if data.Diff, err = cli.git.GetBranchDiff(base); err != nil {
+		return PromptData{}, fmt.Errorf("error getting branch diff: %w", err)
+	}
+	data.Stats = diffStat(data.Diff)
+	return data, nil
+}
@@ -3,6 +3,7 @@ package aigit
 import (
 	"fmt"
 	"os/exec"
+	"strings"
 )
 
 // runCommand executes a command and returns its output and any error
@@ -14,3 +15,20 @@ func runCommand(name string, args ...string) (string, error) {
 	}
 	return string(output), nil
 }
+
+// formatCommand renders a command line with shell quoting, for display
+func formatCommand(name string, args ...string) string {
+	parts := []string{name}
+	for _, arg := range args {
+		parts = append(parts, shellQuote(arg))
+	}
+	return strings.Join(parts, " ")
+}
+
+// shellQuote quotes an argument for a POSIX shell if it contains special characters
+func shellQuote(arg string) string {
+	if arg != "" && !strings.ContainsAny(arg, " \t\n'\"\\$`!*?[]{}()<>|&;#~") {
+		return arg
+	}
+	return "'" + strings.ReplaceAll(arg, "'", `'\''`) + "'"
+}
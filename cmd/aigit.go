@@ -8,21 +8,16 @@ import (
 )
 
 func main() {
-	model := aigit.GetDefaultModel()
-
-	git, err := aigit.NewGit()
+	config, err := aigit.LoadConfig()
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 		os.Exit(1)
 	}
-
-	github, err := aigit.NewGitHub()
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-		os.Exit(1)
+	for _, warning := range config.Warnings() {
+		fmt.Fprintf(os.Stderr, "Warning: %s\n", warning)
 	}
 
-	cli := aigit.NewCli(model, git, github)
+	cli := aigit.NewCli(config, aigit.GetDefaultModel, aigit.NewGitBackend, aigit.NewForge)
 	if err := cli.Run(os.Args); err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 		os.Exit(1)
@@ -0,0 +1,90 @@
+package aigit
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// codeOwnersPaths are the locations GitHub reads CODEOWNERS from, in order
+var codeOwnersPaths = []string{".github/CODEOWNERS", "CODEOWNERS", "docs/CODEOWNERS"}
+
+type codeOwnersRule struct {
+	rule   ignoreRule
+	owners []string
+}
+
+// CodeOwners maps paths to their owners, as listed in a CODEOWNERS file
+type CodeOwners struct {
+	rules []codeOwnersRule
+}
+
+// ParseCodeOwners parses a CODEOWNERS file. Patterns follow gitignore syntax.
+func ParseCodeOwners(text string) *CodeOwners {
+	c := &CodeOwners{}
+	for _, line := range strings.Split(text, "\n") {
+		fields := strings.Fields(line)
+		for i, field := range fields {
+			if strings.HasPrefix(field, "#") {
+				fields = fields[:i]
+				break
+			}
+		}
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "!") {
+			continue
+		}
+		if rule, ok := parseIgnoreRule(fields[0]); ok {
+			c.rules = append(c.rules, codeOwnersRule{rule: rule, owners: fields[1:]})
+		}
+	}
+	return c
+}
+
+// LoadCodeOwners reads the CODEOWNERS file of a repository, returning nil if there is none
+func LoadCodeOwners(root string) (*CodeOwners, error) {
+	for _, path := range codeOwnersPaths {
+		data, err := os.ReadFile(filepath.Join(root, path))
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error reading %s: %w", path, err)
+		}
+		return ParseCodeOwners(string(data)), nil
+	}
+	return nil, nil
+}
+
+// Owners returns the owners of a path. The last matching pattern wins, and a pattern
+// without owners leaves the path unowned.
+func (c *CodeOwners) Owners(path string) []string {
+	var owners []string
+	for _, r := range c.rules {
+		if r.rule.match(path) {
+			owners = r.owners
+		}
+	}
+	return owners
+}
+
+// SuggestReviewers returns the owners of the given paths as reviewers and team reviewers,
+// in order of first appearance. Owners given by email cannot be requested and are skipped.
+func (c *CodeOwners) SuggestReviewers(paths []string) (reviewers, teams []string) {
+	seen := map[string]bool{}
+	for _, path := range paths {
+		for _, owner := range c.Owners(path) {
+			if !strings.HasPrefix(owner, "@") || seen[owner] {
+				continue
+			}
+			seen[owner] = true
+			if handle := strings.TrimPrefix(owner, "@"); strings.Contains(handle, "/") {
+				teams = append(teams, handle)
+			} else {
+				reviewers = append(reviewers, handle)
+			}
+		}
+	}
+	return reviewers, teams
+}
@@ -0,0 +1,31 @@
+package aigit
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("CodeOwners", func() {
+	owners := ParseCodeOwners(`# Default owners
+*               @acme/core
+*.md            @writer docs@acme.com
+/api/           @alice @acme/api   # the API team
+/api/generated/
+`)
+
+	DescribeTable("finding the owners of a path",
+		func(path string, expected []string) {
+			Expect(owners.Owners(path)).To(Equal(expected))
+		},
+		Entry("default", "main.go", []string{"@acme/core"}),
+		Entry("extension at any depth", "docs/guide.md", []string{"@writer", "docs@acme.com"}),
+		Entry("directory", "api/v1/handler.go", []string{"@alice", "@acme/api"}),
+		Entry("later rule without owners", "api/generated/types.go", []string{}),
+	)
+
+	It("should suggest users and teams once each, skipping emails", func() {
+		reviewers, teams := owners.SuggestReviewers([]string{"api/a.go", "README.md", "api/b.go", "main.go"})
+		Expect(reviewers).To(Equal([]string{"alice", "writer"}))
+		Expect(teams).To(Equal([]string{"acme/api", "acme/core"}))
+	})
+})
@@ -0,0 +1,191 @@
+package aigit
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Severities of review findings, from least to most severe
+const (
+	SeverityInfo    = "info"
+	SeverityWarning = "warning"
+	SeverityError   = "error"
+	// SeverityNone disables failing on findings when set as review.fail_on
+	SeverityNone = "none"
+)
+
+const (
+	ReviewFormatText = "text"
+	ReviewFormatJSON = "json"
+)
+
+// severities lists the severities of findings in increasing order
+var severities = []string{SeverityInfo, SeverityWarning, SeverityError}
+
+// Finding is a single comment of a code review, anchored to lines of the new version of a file
+type Finding struct {
+	File      string `json:"file"`
+	StartLine int    `json:"start_line"`
+	EndLine   int    `json:"end_line"`
+	Severity  string `json:"severity"`
+	Message   string `json:"message"`
+}
+
+// Location returns the file and line range of the finding, as in file.go:12-14
+func (f Finding) Location() string {
+	switch {
+	case f.StartLine <= 0:
+		return f.File
+	case f.EndLine <= f.StartLine:
+		return fmt.Sprintf("%s:%d", f.File, f.StartLine)
+	default:
+		return fmt.Sprintf("%s:%d-%d", f.File, f.StartLine, f.EndLine)
+	}
+}
+
+func (f Finding) String() string {
+	return fmt.Sprintf("%s: %s: %s", f.Location(), f.Severity, f.Message)
+}
+
+// ReviewFailedError is returned when a review has findings at or above the review.fail_on severity
+type ReviewFailedError struct {
+	Severity string
+	Count    int
+}
+
+func (e *ReviewFailedError) Error() string {
+	return fmt.Sprintf("review found %d issues of severity %s or higher", e.Count, e.Severity)
+}
+
+// severityRank orders severities, unknown ones rank below info
+func severityRank(severity string) int {
+	for i, s := range severities {
+		if s == severity {
+			return i
+		}
+	}
+	return -1
+}
+
+// ParseFindings parses the findings of a model answer, which should be a JSON array but may be
+// wrapped in prose or a markdown code block. Severities are normalized. Unknown ones such as
+// critical or blocker become error, so that they fail the review rather than slip through.
+func ParseFindings(answer string) ([]Finding, error) {
+	start, end := strings.Index(answer, "["), strings.LastIndex(answer, "]")
+	if start < 0 || end < start {
+		return nil, fmt.Errorf("model answer contains no list of findings: %q", truncateTokens(answer, 50))
+	}
+	var findings []Finding
+	if err := json.Unmarshal([]byte(answer[start:end+1]), &findings); err != nil {
+		return nil, fmt.Errorf("error parsing findings: %w", err)
+	}
+
+	for i := range findings {
+		f := &findings[i]
+		f.Severity = strings.ToLower(strings.TrimSpace(f.Severity))
+		if severityRank(f.Severity) < 0 {
+			f.Severity = SeverityError
+		}
+		if f.EndLine < f.StartLine {
+			f.EndLine = f.StartLine
+		}
+		f.Message = strings.TrimSpace(f.Message)
+	}
+	sort.SliceStable(findings, func(i, j int) bool {
+		if findings[i].File != findings[j].File {
+			return findings[i].File < findings[j].File
+		}
+		return findings[i].StartLine < findings[j].StartLine
+	})
+	return findings, nil
+}
+
+// newReview turns findings into a review of a pull request diff, at the given head commit. Each
+// finding is commented on the last line of its range that is part of the diff. GitHub rejects
+// comments on other lines, so findings outside the diff are listed in the review body instead.
+func newReview(findings []Finding, diff, commit string) Review {
+	positions := diffPositions(diff)
+	review := Review{CommitID: commit, Comments: []ReviewComment{}}
+	var unanchored []string
+	for _, f := range findings {
+		if position := findingPosition(positions[f.File], f); position > 0 {
+			review.Comments = append(review.Comments, ReviewComment{
+				Path:     f.File,
+				Position: position,
+				Body:     fmt.Sprintf("**%s**: %s", f.Severity, f.Message),
+			})
+			continue
+		}
+		unanchored = append(unanchored, fmt.Sprintf("- `%s` **%s**: %s", f.Location(), f.Severity, f.Message))
+	}
+	if len(unanchored) > 0 {
+		review.Body = "Findings outside the changed lines:\n\n" + strings.Join(unanchored, "\n")
+	}
+	return review
+}
+
+// findingPosition returns the diff position of the last line of a finding found in lines,
+// or 0 if none of its lines are part of the diff
+func findingPosition(lines map[int]int, f Finding) int {
+	for line := f.EndLine; line >= f.StartLine && line > 0; line-- {
+		if position, ok := lines[line]; ok {
+			return position
+		}
+	}
+	return 0
+}
+
+// countFindings returns the number of findings at or above a severity
+func countFindings(findings []Finding, severity string) int {
+	if severity == SeverityNone {
+		return 0
+	}
+	count := 0
+	for _, f := range findings {
+		if severityRank(f.Severity) >= severityRank(severity) {
+			count++
+		}
+	}
+	return count
+}
+
+// writeFindings prints findings as text, one per line with indented continuation lines, or as JSON
+func writeFindings(w io.Writer, findings []Finding, format string) error {
+	if format == ReviewFormatJSON {
+		if findings == nil {
+			findings = []Finding{}
+		}
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(findings)
+	}
+
+	if len(findings) == 0 {
+		_, err := fmt.Fprintln(w, "No issues found.")
+		return err
+	}
+	for _, f := range findings {
+		f.Message = strings.ReplaceAll(f.Message, "\n", "\n    ")
+		if _, err := fmt.Fprintln(w, f); err != nil {
+			return err
+		}
+	}
+	counts := make([]string, 0, len(severities))
+	for i := len(severities) - 1; i >= 0; i-- {
+		n := 0
+		for _, f := range findings {
+			if f.Severity == severities[i] {
+				n++
+			}
+		}
+		if n > 0 {
+			counts = append(counts, strconv.Itoa(n)+" "+severities[i])
+		}
+	}
+	_, err := fmt.Fprintf(w, "\n%d findings: %s\n", len(findings), strings.Join(counts, ", "))
+	return err
+}
@@ -0,0 +1,66 @@
+package aigit
+
+import (
+	"bytes"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Code review", func() {
+	It("should parse findings wrapped in a code block", func() {
+		findings, err := ParseFindings("Here is my review:\n```json\n[" +
+			`{"file": "b.go", "start_line": 7, "end_line": 3, "severity": "Warning", "message": " Unchecked error "},` +
+			`{"file": "a.go", "start_line": 1, "end_line": 2, "severity": "critical", "message": "Leaks the token"}` +
+			"]\n```")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(findings).To(Equal([]Finding{
+			{File: "a.go", StartLine: 1, EndLine: 2, Severity: SeverityError, Message: "Leaks the token"},
+			{File: "b.go", StartLine: 7, EndLine: 7, Severity: SeverityWarning, Message: "Unchecked error"},
+		}))
+	})
+
+	It("should fail on unknown severities with the default threshold", func() {
+		findings, err := ParseFindings(`[{"file": "a.go", "severity": "high", "message": "SQL injection"},` +
+			`{"file": "b.go", "severity": "blocker", "message": "Deletes data"}]`)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(findings[0].Severity).To(Equal(SeverityError))
+		Expect(findings[1].Severity).To(Equal(SeverityError))
+		Expect(countFindings(findings, NewConfig().Get("review.fail_on"))).To(Equal(2))
+	})
+
+	It("should reject answers without findings", func() {
+		_, err := ParseFindings("Looks good to me!")
+		Expect(err).To(MatchError(ContainSubstring("no list of findings")))
+	})
+
+	It("should count findings at or above a severity", func() {
+		findings := []Finding{{Severity: SeverityInfo}, {Severity: SeverityWarning}, {Severity: SeverityError}}
+		Expect(countFindings(findings, SeverityInfo)).To(Equal(3))
+		Expect(countFindings(findings, SeverityError)).To(Equal(1))
+		Expect(countFindings(findings, SeverityNone)).To(Equal(0))
+	})
+
+	It("should print findings with their location", func() {
+		var out bytes.Buffer
+		Expect(writeFindings(&out, []Finding{
+			{File: "a.go", StartLine: 3, EndLine: 5, Severity: SeverityError, Message: "Nil dereference\nwhen empty"},
+			{File: "a.go", StartLine: 9, EndLine: 9, Severity: SeverityInfo, Message: "Typo"},
+		}, ReviewFormatText)).To(Succeed())
+		Expect(out.String()).To(Equal("a.go:3-5: error: Nil dereference\n    when empty\na.go:9: info: Typo\n\n2 findings: 1 error, 1 info\n"))
+	})
+
+	It("should anchor findings to diff positions and list the others in the body", func() {
+		diff := "diff --git a/a.go b/a.go\n--- a/a.go\n+++ b/a.go\n@@ -1,2 +1,3 @@\n ctx\n-old\n+new\n+more\n"
+		review := newReview([]Finding{
+			{File: "a.go", StartLine: 2, EndLine: 9, Severity: SeverityError, Message: "Wrong"},
+			{File: "a.go", StartLine: 40, EndLine: 41, Severity: SeverityInfo, Message: "Elsewhere"},
+			{File: "b.go", StartLine: 1, EndLine: 1, Severity: SeverityWarning, Message: "Not in the diff"},
+		}, diff, "abc123")
+		Expect(review).To(Equal(Review{
+			CommitID: "abc123",
+			Body:     "Findings outside the changed lines:\n\n- `a.go:40-41` **info**: Elsewhere\n- `b.go:1` **warning**: Not in the diff",
+			Comments: []ReviewComment{{Path: "a.go", Position: 4, Body: "**error**: Wrong"}},
+		}))
+	})
+})
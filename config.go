@@ -0,0 +1,557 @@
+package aigit
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+var ErrUnknownSetting = errors.New("unknown setting")
+
+const (
+	SourceDefault = "default"
+	SourceEnv     = "env"
+	SourceFlag    = "flag"
+)
+
+// Setting describes a configuration key, its default value and how it is validated
+type Setting struct {
+	Key         string
+	Default     string
+	Description string
+	// Env overrides the environment variable derived from the key
+	Env string
+	// Validate checks a value before it is accepted
+	Validate func(value string) error
+	// UserOnly keeps repository config files from setting the key. Any cloned repository can
+	// ship one, so keys deciding where tokens are sent are left to the user.
+	UserOnly bool
+	// Secret masks the value when settings are printed
+	Secret bool
+}
+
+// EnvVar returns the environment variable that sets this key, e.g. AIGIT_COMMIT_MAX_TOKENS
+func (s Setting) EnvVar() string {
+	if s.Env != "" {
+		return s.Env
+	}
+	return "AIGIT_" + strings.ToUpper(strings.NewReplacer(".", "_", "-", "_").Replace(s.Key))
+}
+
+// settings lists every key aigit understands
+var settings = []Setting{
+	{Key: "model.provider", Env: "AIGIT_PROVIDER", Description: "Model provider to use, detected from the environment when empty"},
+	{Key: "model.name", Description: "Model name used by all commands unless overridden per command"},
+	{Key: "ollama.host", Env: "OLLAMA_HOST", Description: "Address of the Ollama server"},
+	{Key: "llamacpp.host", Env: "LLAMACPP_HOST", Description: "Address of the llama.cpp server"},
+	{Key: "openai.base_url", Env: "OPENAI_BASE_URL", Description: "Base URL of the OpenAI-compatible API, the API key is only read from OPENAI_API_KEY", UserOnly: true},
+	{Key: "commit.model", Description: "Model name used for commit and amend messages"},
+	{Key: "commit.max_tokens", Default: "512", Description: "Maximum tokens generated for commit messages", Validate: validateMaxTokens},
+	{Key: "commit.temperature", Default: "0.2", Description: "Sampling temperature for commit messages", Validate: validateTemperature},
+	{Key: "commit.candidates", Default: "1", Description: "Number of alternative commit messages to choose from", Validate: validateCandidates},
+	{Key: "pr.model", Description: "Model name used for pull request descriptions"},
+	{Key: "pr.max_tokens", Default: "4096", Description: "Maximum tokens generated for pull request descriptions", Validate: validateMaxTokens},
+	{Key: "pr.temperature", Default: "0.5", Description: "Sampling temperature for pull request descriptions", Validate: validateTemperature},
+	{Key: "pr.base", Description: "Base branch for pull requests, detected when empty"},
+	{Key: "pr.draft", Default: "false", Description: "Open pull requests as drafts", Validate: validateBool},
+	{Key: "pr.reviewers", Description: "Users to request reviews from, one per line"},
+	{Key: "pr.team_reviewers", Description: "Teams to request reviews from as org/team, one per line"},
+	{Key: "pr.labels", Description: "Labels to add to pull requests, one per line"},
+	{Key: "pr.assignees", Description: "Users to assign pull requests to, one per line"},
+	{Key: "pr.milestone", Description: "Milestone to add pull requests to"},
+	{Key: "pr.suggest_reviewers", Default: "false", Description: "Request reviews from the CODEOWNERS of the changed files", Validate: validateBool},
+	{Key: "pr.template", Description: "Pull request template to follow, relative to the repository root or named in PULL_REQUEST_TEMPLATE, detected when empty, none to disable"},
+	{Key: "review.model", Description: "Model name used for code reviews"},
+	{Key: "review.max_tokens", Default: "4096", Description: "Maximum tokens generated for code reviews", Validate: validateMaxTokens},
+	{Key: "review.temperature", Default: "0.2", Description: "Sampling temperature for code reviews", Validate: validateTemperature},
+	{Key: "review.format", Default: ReviewFormatText, Description: "Output format of code reviews: text or json", Validate: validateReviewFormat},
+	{Key: "review.fail_on", Default: SeverityError, Description: "Lowest severity of findings that makes a review fail: info, warning, error or none", Validate: validateSeverity},
+	{Key: "diff.max_tokens", Default: "8000", Description: "Token budget for a diff in a single prompt, larger diffs are summarized in chunks", Validate: validateMaxTokens},
+	{Key: "diff.parallelism", Default: "4", Description: "Number of diff chunks summarized concurrently", Validate: validateParallelism},
+	{Key: "forge.type", Default: ForgeAuto, Description: "Forge pull requests are opened on: auto detects it from the origin remote, github, gitlab or gitea (also Forgejo)", Validate: validateForgeType, UserOnly: true},
+	{Key: "forge.url", Description: "Base URL of the forge API server, derived from the origin remote when empty", UserOnly: true},
+	{Key: "github.token", Env: "GITHUB_TOKEN", Description: "GitHub token used to call the API of github.com and github.hosts directly, the GitHub CLI is used when empty", UserOnly: true, Secret: true},
+	{Key: "github.hosts", Description: "GitHub Enterprise Server host names that github.token may be sent to, one per line", UserOnly: true},
+	{Key: "gitlab.token", Env: "GITLAB_TOKEN", Description: "GitLab personal access token with the api scope, sent only to gitlab.com and gitlab.hosts", UserOnly: true, Secret: true},
+	{Key: "gitlab.hosts", Description: "Self-hosted GitLab host names, one per line", UserOnly: true},
+	{Key: "gitea.token", Env: "GITEA_TOKEN", Description: "Gitea or Forgejo access token with repository write access, sent only to gitea.hosts", UserOnly: true, Secret: true},
+	{Key: "gitea.hosts", Description: "Gitea and Forgejo host names that gitea.token may be sent to, including codeberg.org, one per line", UserOnly: true},
+	{Key: "git.backend", Default: GitBackendCli, Description: "Git implementation: cli runs the git binary, go-git needs no git installed", Validate: validateGitBackend},
+	{Key: "redact.mode", Default: RedactMask, Description: "What to do with secrets found in diffs: mask, abort or off", Validate: validateRedactMode},
+	{Key: "redact.patterns", Description: "Extra regular expressions to redact, one per line", Validate: validatePatterns},
+	{Key: "prompts.dir", Default: ".aigit/prompts", Description: "Directory of prompt template overrides, relative to the repository root"},
+	{Key: "prompts.ticket_pattern", Default: `[A-Z][A-Z0-9]+-[0-9]+`, Description: "Pattern extracting a ticket ID from the branch name", Validate: validateRegexp},
+	{Key: "prompts.recent_commits", Default: "5", Description: "Number of recent commit subjects available to prompts", Validate: validateCount},
+}
+
+// Settings returns every known configuration key
+func Settings() []Setting {
+	return settings
+}
+
+// LookupSetting finds the setting definition for a key
+func LookupSetting(key string) (Setting, error) {
+	for _, s := range settings {
+		if s.Key == key {
+			return s, nil
+		}
+	}
+	return Setting{}, fmt.Errorf("%w: %s", ErrUnknownSetting, key)
+}
+
+// validateSetting checks that key is known and value is acceptable for it. Empty values reset a key.
+func validateSetting(key, value string) error {
+	s, err := LookupSetting(key)
+	if err != nil {
+		return err
+	}
+	if s.Validate != nil && value != "" {
+		if err := s.Validate(value); err != nil {
+			return fmt.Errorf("invalid value for %s: %w", key, err)
+		}
+	}
+	return nil
+}
+
+type configValue struct {
+	value  string
+	source string
+}
+
+// Config holds settings merged from defaults, config files, environment variables and flags.
+// Later layers override earlier ones, and each value remembers where it came from.
+type Config struct {
+	values   map[string]configValue
+	warnings []string
+}
+
+// NewConfig returns a config holding only the default values
+func NewConfig() *Config {
+	cfg := &Config{values: map[string]configValue{}}
+	for _, s := range settings {
+		cfg.values[s.Key] = configValue{value: s.Default, source: SourceDefault}
+	}
+	return cfg
+}
+
+// UserConfigPath returns the path of the per-user config file
+func UserConfigPath() (string, error) {
+	if dir := os.Getenv("XDG_CONFIG_HOME"); dir != "" {
+		return filepath.Join(dir, "aigit", "config.yaml"), nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("could not find home directory: %w", err)
+	}
+	return filepath.Join(home, ".config", "aigit", "config.yaml"), nil
+}
+
+// RepoConfigFile is the name of the repository level config file
+const RepoConfigFile = ".aigit.yaml"
+
+// RepoRoot returns the root of the git repository containing the working directory
+func RepoRoot() (string, error) {
+	dir, err := os.Getwd()
+	if err != nil {
+		return "", fmt.Errorf("could not get working directory: %w", err)
+	}
+	for {
+		if _, err := os.Stat(filepath.Join(dir, ".git")); err == nil {
+			return dir, nil
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", fmt.Errorf("not inside a git repository")
+		}
+		dir = parent
+	}
+}
+
+// RepoConfigPath returns the path of the repository config file
+func RepoConfigPath() (string, error) {
+	root, err := RepoRoot()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(root, RepoConfigFile), nil
+}
+
+// LoadConfig merges the defaults, the user config file, the repository config file
+// and environment variables, in increasing order of precedence. Flags are applied on top by the CLI.
+func LoadConfig() (*Config, error) {
+	cfg := NewConfig()
+
+	path, err := UserConfigPath()
+	if err != nil {
+		return nil, err
+	}
+	if err := cfg.LoadFile(path); err != nil {
+		return nil, err
+	}
+
+	// The repository file is optional, aigit may be invoked outside a repository
+	if path, err := RepoConfigPath(); err == nil {
+		cfg.LoadRepoFile(path)
+	}
+
+	if err := cfg.LoadEnv(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// LoadFile merges a YAML config file. Missing files are ignored.
+func (c *Config) LoadFile(path string) error {
+	flat, err := readConfigFile(path)
+	if err != nil {
+		return err
+	}
+	for key, value := range flat {
+		if err := c.Set(key, value, path); err != nil {
+			return fmt.Errorf("error in config file %s: %w", path, err)
+		}
+	}
+	return nil
+}
+
+// LoadRepoFile merges a repository config file. Any repository can ship one, possibly written
+// for another version of aigit, so instead of failing every command, unknown, invalid and user
+// only keys are skipped with a warning. An unreadable file is skipped entirely.
+func (c *Config) LoadRepoFile(path string) {
+	flat, err := readConfigFile(path)
+	if err != nil {
+		c.warnings = append(c.warnings, fmt.Sprintf("ignoring %s: %v", path, err))
+		return
+	}
+	keys := make([]string, 0, len(flat))
+	for key := range flat {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if err := c.Set(key, flat[key], path); err != nil {
+			c.warnings = append(c.warnings, fmt.Sprintf("ignoring %s in %s: %v", key, path, err))
+		}
+	}
+}
+
+// Warnings returns the problems found while loading config files that did not stop loading
+func (c *Config) Warnings() []string {
+	return c.warnings
+}
+
+// readConfigFile reads a YAML config file into dotted keys. Missing files hold no keys.
+func readConfigFile(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading config file: %w", err)
+	}
+
+	var tree map[string]any
+	if err := yaml.Unmarshal(data, &tree); err != nil {
+		return nil, fmt.Errorf("error parsing config file %s: %w", path, err)
+	}
+	flat := map[string]string{}
+	flattenConfig("", tree, flat)
+	return flat, nil
+}
+
+// LoadEnv merges settings from AIGIT_* environment variables
+func (c *Config) LoadEnv() error {
+	for _, s := range settings {
+		if value, exists := os.LookupEnv(s.EnvVar()); exists {
+			if err := c.Set(s.Key, value, SourceEnv+" "+s.EnvVar()); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Set validates and stores a value, recording its source
+func (c *Config) Set(key, value, source string) error {
+	if err := validateSetting(key, value); err != nil {
+		return err
+	}
+	if err := checkRepoConfig(key, source); err != nil {
+		return err
+	}
+	c.values[key] = configValue{value: value, source: source}
+	return nil
+}
+
+// Get returns the value of a key
+func (c *Config) Get(key string) string {
+	return c.values[key].value
+}
+
+// Source returns where the value of a key came from
+func (c *Config) Source(key string) string {
+	return c.values[key].source
+}
+
+// FromRepoConfig reports whether the value of a key was set by a repository config file, which
+// comes with the repository and cannot be trusted like the user's own settings
+func (c *Config) FromRepoConfig(key string) bool {
+	return isRepoConfig(c.Source(key))
+}
+
+// isRepoConfig reports whether a config source is a repository config file
+func isRepoConfig(source string) bool {
+	return filepath.Base(source) == RepoConfigFile
+}
+
+// checkRepoConfig refuses user only keys from repository config files
+func checkRepoConfig(key, source string) error {
+	if s, err := LookupSetting(key); err == nil && s.UserOnly && isRepoConfig(source) {
+		return fmt.Errorf("%s can only be set in the user config or environment, not in %s", key, RepoConfigFile)
+	}
+	return nil
+}
+
+// Int returns the value of a key as an integer. Values are validated when set,
+// so a parse failure falls back to zero.
+func (c *Config) Int(key string) int {
+	v, _ := strconv.Atoi(c.Get(key))
+	return v
+}
+
+// Float returns the value of a key as a float
+func (c *Config) Float(key string) float64 {
+	v, _ := strconv.ParseFloat(c.Get(key), 64)
+	return v
+}
+
+// Bool returns the value of a key as a boolean
+func (c *Config) Bool(key string) bool {
+	v, _ := strconv.ParseBool(c.Get(key))
+	return v
+}
+
+// List returns the value of a key as a list. Lists are stored one item per line.
+func (c *Config) List(key string) []string {
+	return splitLines(c.Get(key))
+}
+
+// Keys returns all known keys in sorted order
+func (c *Config) Keys() []string {
+	keys := make([]string, 0, len(c.values))
+	for key := range c.values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// WriteConfigFile sets a key in the YAML file at path, creating the file if needed.
+// Other keys in the file are preserved.
+func WriteConfigFile(path, key, value string) error {
+	if err := validateSetting(key, value); err != nil {
+		return err
+	}
+	if err := checkRepoConfig(key, path); err != nil {
+		return err
+	}
+
+	tree := map[string]any{}
+	data, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("error reading config file: %w", err)
+	}
+	if err := yaml.Unmarshal(data, &tree); err != nil {
+		return fmt.Errorf("error parsing config file %s: %w", path, err)
+	}
+	if tree == nil {
+		tree = map[string]any{}
+	}
+
+	// Walk down to the parent map of the key, creating sections as needed
+	parts := strings.Split(key, ".")
+	node := tree
+	for _, part := range parts[:len(parts)-1] {
+		child, ok := node[part].(map[string]any)
+		if !ok {
+			child = map[string]any{}
+			node[part] = child
+		}
+		node = child
+	}
+	node[parts[len(parts)-1]] = yamlScalar(value)
+
+	data, err = yaml.Marshal(tree)
+	if err != nil {
+		return fmt.Errorf("error encoding config file: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("error creating config directory: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		return fmt.Errorf("error writing config file: %w", err)
+	}
+	return nil
+}
+
+// yamlScalar converts numeric and boolean strings so they are written unquoted
+func yamlScalar(value string) any {
+	if v, err := strconv.Atoi(value); err == nil {
+		return v
+	}
+	if v, err := strconv.ParseFloat(value, 64); err == nil {
+		return v
+	}
+	if v, err := strconv.ParseBool(value); err == nil {
+		return v
+	}
+	return value
+}
+
+// flattenConfig turns nested YAML maps into dotted keys
+func flattenConfig(prefix string, tree map[string]any, out map[string]string) {
+	for key, value := range tree {
+		if prefix != "" {
+			key = prefix + "." + key
+		}
+		switch v := value.(type) {
+		case map[string]any:
+			flattenConfig(key, v, out)
+		case nil:
+			out[key] = ""
+		case []any:
+			items := make([]string, len(v))
+			for i, item := range v {
+				items[i] = fmt.Sprint(item)
+			}
+			out[key] = strings.Join(items, "\n")
+		default:
+			out[key] = fmt.Sprint(v)
+		}
+	}
+}
+
+func validateMaxTokens(value string) error {
+	v, err := strconv.Atoi(value)
+	if err != nil {
+		return fmt.Errorf("%q is not an integer", value)
+	}
+	if v <= 0 {
+		return fmt.Errorf("must be positive, got %d", v)
+	}
+	return nil
+}
+
+func validateTemperature(value string) error {
+	v, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return fmt.Errorf("%q is not a number", value)
+	}
+	if v < 0 || v > 2 {
+		return fmt.Errorf("must be between 0 and 2, got %g", v)
+	}
+	return nil
+}
+
+func validateRegexp(value string) error {
+	_, err := regexp.Compile(value)
+	return err
+}
+
+func validateCount(value string) error {
+	v, err := strconv.Atoi(value)
+	if err != nil {
+		return fmt.Errorf("%q is not an integer", value)
+	}
+	if v < 0 {
+		return fmt.Errorf("must not be negative, got %d", v)
+	}
+	return nil
+}
+
+func validateParallelism(value string) error {
+	v, err := strconv.Atoi(value)
+	if err != nil {
+		return fmt.Errorf("%q is not a number of concurrent summaries", value)
+	}
+	if v < 1 {
+		return fmt.Errorf("at least 1 diff chunk must be summarized at a time, got %d", v)
+	}
+	return nil
+}
+
+func validateBool(value string) error {
+	if _, err := strconv.ParseBool(value); err != nil {
+		return fmt.Errorf("must be true or false, got %q", value)
+	}
+	return nil
+}
+
+// MaxCandidates limits how many alternative messages can be requested at once
+const MaxCandidates = 10
+
+func validateCandidates(value string) error {
+	v, err := strconv.Atoi(value)
+	if err != nil {
+		return fmt.Errorf("%q is not an integer", value)
+	}
+	if v < 1 || v > MaxCandidates {
+		return fmt.Errorf("must be between 1 and %d, got %d", MaxCandidates, v)
+	}
+	return nil
+}
+
+func validateRedactMode(value string) error {
+	switch value {
+	case RedactMask, RedactAbort, RedactOff:
+		return nil
+	}
+	return fmt.Errorf("must be %s, %s or %s, got %q", RedactMask, RedactAbort, RedactOff, value)
+}
+
+func validateGitBackend(value string) error {
+	switch value {
+	case GitBackendCli, GitBackendGoGit:
+		return nil
+	}
+	return fmt.Errorf("must be %s or %s, got %q", GitBackendCli, GitBackendGoGit, value)
+}
+
+func validateForgeType(value string) error {
+	switch value {
+	case ForgeAuto, ForgeGitHub, ForgeGitLab, ForgeGitea:
+		return nil
+	}
+	return fmt.Errorf("must be %s, %s, %s or %s, got %q", ForgeAuto, ForgeGitHub, ForgeGitLab, ForgeGitea, value)
+}
+
+func validateReviewFormat(value string) error {
+	switch value {
+	case ReviewFormatText, ReviewFormatJSON:
+		return nil
+	}
+	return fmt.Errorf("must be %s or %s, got %q", ReviewFormatText, ReviewFormatJSON, value)
+}
+
+func validateSeverity(value string) error {
+	if value == SeverityNone || severityRank(value) >= 0 {
+		return nil
+	}
+	return fmt.Errorf("must be %s, %s, %s or %s, got %q", SeverityInfo, SeverityWarning, SeverityError, SeverityNone, value)
+}
+
+func validatePatterns(value string) error {
+	for _, line := range splitLines(value) {
+		if err := validateRegexp(line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
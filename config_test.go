@@ -0,0 +1,102 @@
+package aigit
+
+import (
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Config", func() {
+	var (
+		cfg  *Config
+		path string
+	)
+
+	BeforeEach(func() {
+		cfg = NewConfig()
+		path = filepath.Join(GinkgoT().TempDir(), "config.yaml")
+	})
+
+	It("should start with the defaults", func() {
+		Expect(cfg.Int("commit.max_tokens")).To(Equal(512))
+		Expect(cfg.Int("pr.max_tokens")).To(Equal(4096))
+		Expect(cfg.Source("pr.max_tokens")).To(Equal(SourceDefault))
+	})
+
+	It("should merge nested keys from a file", func() {
+		Expect(os.WriteFile(path, []byte("pr:\n  max_tokens: 8000\n  temperature: 0.7\n"), 0o644)).To(Succeed())
+		Expect(cfg.LoadFile(path)).To(Succeed())
+		Expect(cfg.Int("pr.max_tokens")).To(Equal(8000))
+		Expect(cfg.Float("pr.temperature")).To(Equal(0.7))
+		Expect(cfg.Source("pr.max_tokens")).To(Equal(path))
+	})
+
+	It("should let environment variables override files", func() {
+		Expect(os.WriteFile(path, []byte("commit:\n  model: from-file\n"), 0o644)).To(Succeed())
+		GinkgoT().Setenv("AIGIT_COMMIT_MODEL", "from-env")
+		Expect(cfg.LoadFile(path)).To(Succeed())
+		Expect(cfg.LoadEnv()).To(Succeed())
+		Expect(cfg.Get("commit.model")).To(Equal("from-env"))
+		Expect(cfg.Source("commit.model")).To(Equal("env AIGIT_COMMIT_MODEL"))
+	})
+
+	It("should reject invalid values", func() {
+		Expect(cfg.Set("commit.max_tokens", "-5", SourceFlag)).To(MatchError(ContainSubstring("must be positive")))
+		Expect(cfg.Set("diff.parallelism", "0", SourceFlag)).To(MatchError(ContainSubstring("invalid value for diff.parallelism: at least 1 diff chunk")))
+		Expect(cfg.Set("pr.temperature", "hot", SourceFlag)).To(MatchError(ContainSubstring("not a number")))
+	})
+
+	It("should reject unknown keys", func() {
+		Expect(os.WriteFile(path, []byte("comit:\n  model: typo\n"), 0o644)).To(Succeed())
+		Expect(cfg.LoadFile(path)).To(MatchError(ErrUnknownSetting))
+	})
+
+	It("should refuse keys deciding where tokens are sent from repository config files", func() {
+		repoPath := filepath.Join(filepath.Dir(path), RepoConfigFile)
+		Expect(os.WriteFile(repoPath, []byte("gitlab:\n  hosts: [evil.example.com]\n"), 0o644)).To(Succeed())
+		Expect(cfg.LoadFile(repoPath)).To(MatchError(ContainSubstring("gitlab.hosts can only be set in the user config")))
+		Expect(WriteConfigFile(repoPath, "github.token", "ghp_secret")).To(MatchError(ContainSubstring("github.token can only be set in the user config")))
+		cfg.LoadRepoFile(repoPath)
+		Expect(cfg.List("gitlab.hosts")).To(BeEmpty())
+		Expect(cfg.Warnings()).To(ConsistOf(ContainSubstring("gitlab.hosts can only be set in the user config")))
+		Expect(WriteConfigFile(repoPath, "forge.url", "https://evil.example.com")).To(HaveOccurred())
+
+		Expect(os.WriteFile(path, []byte("gitlab:\n  hosts: [git.example.com]\n"), 0o644)).To(Succeed())
+		Expect(cfg.LoadFile(path)).To(Succeed())
+		Expect(cfg.List("gitlab.hosts")).To(Equal([]string{"git.example.com"}))
+	})
+
+	It("should skip unknown and invalid keys of repository config files with a warning", func() {
+		repoPath := filepath.Join(filepath.Dir(path), RepoConfigFile)
+		Expect(os.WriteFile(repoPath, []byte("future_key: 1\ncommit:\n  max_tokens: -5\n  model: kept\n"), 0o644)).To(Succeed())
+		cfg.LoadRepoFile(repoPath)
+		Expect(cfg.Get("commit.model")).To(Equal("kept"))
+		Expect(cfg.Int("commit.max_tokens")).To(Equal(512))
+		Expect(cfg.Warnings()).To(HaveLen(2))
+
+		Expect(os.WriteFile(repoPath, []byte("commit: [broken"), 0o644)).To(Succeed())
+		cfg.LoadRepoFile(repoPath)
+		Expect(cfg.Warnings()).To(HaveLen(3))
+	})
+
+	It("should write keys to a file without dropping others", func() {
+		Expect(os.WriteFile(path, []byte("commit:\n  model: kept\n"), 0o644)).To(Succeed())
+		Expect(WriteConfigFile(path, "commit.max_tokens", "300")).To(Succeed())
+		Expect(WriteConfigFile(path, "pr.base", "develop")).To(Succeed())
+		Expect(cfg.LoadFile(path)).To(Succeed())
+		Expect(cfg.Get("commit.model")).To(Equal("kept"))
+		Expect(cfg.Int("commit.max_tokens")).To(Equal(300))
+		Expect(cfg.Get("pr.base")).To(Equal("develop"))
+	})
+
+	It("should refuse to write invalid values", func() {
+		Expect(WriteConfigFile(path, "commit.max_tokens", "many")).To(MatchError(ContainSubstring("not an integer")))
+		Expect(path).NotTo(BeAnExistingFile())
+	})
+
+	It("should ignore missing files", func() {
+		Expect(cfg.LoadFile(filepath.Join(GinkgoT().TempDir(), "missing.yaml"))).To(Succeed())
+	})
+})
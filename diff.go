@@ -0,0 +1,342 @@
+package aigit
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/sync/errgroup"
+)
+
+// maxReduceRounds limits how many times summaries are summarized again to fit the budget
+const maxReduceRounds = 3
+
+// FileDiff is the part of a unified diff describing a single file
+type FileDiff struct {
+	// Path is the path of the file in the new tree
+	Path string
+	// Header holds the diff --git line and everything up to the first hunk
+	Header string
+	// Hunks holds each @@ section including its header line
+	Hunks []string
+}
+
+// String reassembles the file diff
+func (f FileDiff) String() string {
+	return f.Header + strings.Join(f.Hunks, "")
+}
+
+// estimateTokens approximates the number of tokens in a text, at roughly four characters per token
+func estimateTokens(text string) int {
+	return (len(text) + 3) / 4
+}
+
+// parseDiff splits a unified diff into per-file sections
+func parseDiff(diff string) []FileDiff {
+	var files []FileDiff
+	var current *FileDiff
+	var hunk *strings.Builder
+
+	flushHunk := func() {
+		if current != nil && hunk != nil {
+			current.Hunks = append(current.Hunks, hunk.String())
+		}
+		hunk = nil
+	}
+
+	for _, line := range strings.SplitAfter(diff, "\n") {
+		if line == "" {
+			continue
+		}
+		switch {
+		case strings.HasPrefix(line, "diff --git "):
+			flushHunk()
+			files = append(files, FileDiff{Path: diffPath(line), Header: line})
+			current = &files[len(files)-1]
+		case current == nil:
+			// Text before the first file header, keep it as a file of its own
+			files = append(files, FileDiff{Header: line})
+			current = &files[len(files)-1]
+		case strings.HasPrefix(line, "@@"):
+			flushHunk()
+			hunk = &strings.Builder{}
+			hunk.WriteString(line)
+		case hunk != nil:
+			hunk.WriteString(line)
+		default:
+			current.Header += line
+		}
+	}
+	flushHunk()
+	return files
+}
+
+// diffPath extracts the new path from a "diff --git a/x b/y" line
+func diffPath(line string) string {
+	line = strings.TrimSpace(strings.TrimPrefix(line, "diff --git "))
+	if i := strings.LastIndex(line, " b/"); i >= 0 {
+		return line[i+3:]
+	}
+	return line
+}
+
+// diffStat summarizes a diff per file like git diff --stat, with the lines added and removed
+func diffStat(diff string) string {
+	var b strings.Builder
+	files, added, removed := 0, 0, 0
+	for _, file := range parseDiff(diff) {
+		if file.Path == "" {
+			continue
+		}
+		plus, minus := 0, 0
+		for _, hunk := range file.Hunks {
+			for _, line := range strings.Split(hunk, "\n")[1:] {
+				switch {
+				case strings.HasPrefix(line, "+"):
+					plus++
+				case strings.HasPrefix(line, "-"):
+					minus++
+				}
+			}
+		}
+		fmt.Fprintf(&b, "%s | +%d -%d\n", file.Path, plus, minus)
+		files, added, removed = files+1, added+plus, removed+minus
+	}
+	if files == 0 {
+		return ""
+	}
+	fmt.Fprintf(&b, "%d files changed, %d insertions(+), %d deletions(-)\n", files, added, removed)
+	return b.String()
+}
+
+// numberDiffLines prefixes the lines of each hunk with their line number in the new version of
+// the file, so that a model can refer to them. Removed lines have no number.
+func numberDiffLines(diff string) string {
+	var b strings.Builder
+	line, inHunk := 0, false
+	for _, text := range strings.SplitAfter(diff, "\n") {
+		switch {
+		case strings.HasPrefix(text, "diff --git "):
+			inHunk = false
+		case strings.HasPrefix(text, "@@"):
+			line, inHunk = hunkNewStart(text), true
+		case !inHunk || text == "" || strings.HasPrefix(text, "\\"):
+		case strings.HasPrefix(text, "-"):
+			text = "      " + text
+		default:
+			text = fmt.Sprintf("%5d %s", line, text)
+			line++
+		}
+		b.WriteString(text)
+	}
+	return b.String()
+}
+
+// diffPositions maps the lines of the new version of each file in a diff to their position in
+// the diff of the file, which is how GitHub anchors review comments: position 1 is the line
+// below the first hunk header, and later hunk headers count as lines too.
+func diffPositions(diff string) map[string]map[int]int {
+	positions := map[string]map[int]int{}
+	for _, file := range parseDiff(diff) {
+		if file.Path == "" {
+			continue
+		}
+		lines := map[int]int{}
+		position := 0
+		for i, hunk := range file.Hunks {
+			hunkLines := strings.Split(strings.TrimSuffix(hunk, "\n"), "\n")
+			if i > 0 {
+				position++
+			}
+			line := hunkNewStart(hunkLines[0])
+			for _, text := range hunkLines[1:] {
+				position++
+				if !strings.HasPrefix(text, "-") && !strings.HasPrefix(text, "\\") {
+					lines[line] = position
+					line++
+				}
+			}
+		}
+		positions[file.Path] = lines
+	}
+	return positions
+}
+
+// hunkNewStart returns the first line in the new file of a hunk header such as "@@ -1,4 +2,5 @@"
+func hunkNewStart(header string) int {
+	_, rest, _ := strings.Cut(header, " +")
+	start, _, _ := strings.Cut(rest, " ")
+	start, _, _ = strings.Cut(start, ",")
+	n, _ := strconv.Atoi(start)
+	return n
+}
+
+// chunkDiff packs file diffs into chunks of at most budget tokens. Files larger than the
+// budget are split between hunks, repeating the file header, and oversized hunks are truncated.
+func chunkDiff(files []FileDiff, budget int) []string {
+	var chunks []string
+	var current strings.Builder
+
+	add := func(text string) {
+		if current.Len() > 0 && estimateTokens(current.String())+estimateTokens(text) > budget {
+			chunks = append(chunks, current.String())
+			current.Reset()
+		}
+		current.WriteString(text)
+	}
+
+	for _, file := range files {
+		text := file.String()
+		if estimateTokens(text) <= budget {
+			add(text)
+			continue
+		}
+
+		// Split the file between hunks, each piece carrying the file header
+		piece := file.Header
+		for _, hunk := range file.Hunks {
+			if estimateTokens(piece+hunk) > budget && piece != file.Header {
+				add(piece)
+				piece = file.Header
+			}
+			piece += truncateTokens(hunk, budget-estimateTokens(file.Header))
+		}
+		add(piece)
+	}
+	if current.Len() > 0 {
+		chunks = append(chunks, current.String())
+	}
+	return chunks
+}
+
+// truncateTokens cuts text down to roughly the given number of tokens, on a rune boundary
+func truncateTokens(text string, tokens int) string {
+	limit := tokens * 4
+	if limit <= 0 || len(text) <= limit {
+		return text
+	}
+	for limit > 0 && !utf8.RuneStart(text[limit]) {
+		limit--
+	}
+	return text[:limit] + "\n[... truncated ...]\n"
+}
+
+// DiffSummarizer condenses diffs that are too large for a single prompt by summarizing
+// chunks of the diff in parallel and combining the summaries
+type DiffSummarizer struct {
+	Model       Model
+	Prompts     *Prompts
+	Budget      int
+	Parallelism int
+	// Log receives a description of the chosen strategy, it may be nil
+	Log func(format string, args ...any)
+	// Quiet hides the progress spinner, which would corrupt machine readable output
+	Quiet bool
+}
+
+// Summarize returns the diff unchanged if it fits the budget. Otherwise it returns
+// a combined summary of the diff, and true.
+func (s *DiffSummarizer) Summarize(ctx context.Context, diff string) (string, bool, error) {
+	tokens := estimateTokens(diff)
+	if tokens <= s.Budget {
+		s.logf("Diff is ~%d tokens, within the budget of %d: sending it directly", tokens, s.Budget)
+		return diff, false, nil
+	}
+
+	files := parseDiff(diff)
+	chunks := chunkDiff(files, s.Budget)
+	s.logf("Diff is ~%d tokens across %d files, over the budget of %d: summarizing %d chunks with up to %d in parallel",
+		tokens, len(files), s.Budget, len(chunks), s.Parallelism)
+
+	for round := 1; ; round++ {
+		var summaries []string
+		summarize := func() error {
+			var err error
+			summaries, err = s.summarizeChunks(ctx, chunks)
+			return err
+		}
+		var err error
+		if s.Quiet {
+			err = summarize()
+		} else {
+			err = WithSpinner(fmt.Sprintf("Summarizing %d chunks of changes...", len(chunks)), summarize)
+		}
+		if err != nil {
+			return "", false, fmt.Errorf("error summarizing changes: %w", err)
+		}
+
+		combined := strings.Join(summaries, "\n\n")
+		tokens := estimateTokens(combined)
+		if tokens <= s.Budget || round == maxReduceRounds {
+			s.logf("Combined summaries are ~%d tokens after %d round(s)", tokens, round)
+			return combined, true, nil
+		}
+
+		// The summaries themselves are too large, summarize them again
+		chunks = chunkText(summaries, s.Budget)
+		s.logf("Combined summaries are ~%d tokens, still over budget: reducing %d chunks again", tokens, len(chunks))
+	}
+}
+
+// summarizeChunks summarizes each chunk concurrently, preserving chunk order in the result
+func (s *DiffSummarizer) summarizeChunks(ctx context.Context, chunks []string) ([]string, error) {
+	summaries := make([]string, len(chunks))
+	group, ctx := errgroup.WithContext(ctx)
+	group.SetLimit(max(s.Parallelism, 1))
+	for i, chunk := range chunks {
+		group.Go(func() error {
+			query, err := s.Prompts.Render(PromptSummarize, PromptData{Diff: chunk, Files: chunkFiles(chunk)})
+			if err != nil {
+				return err
+			}
+			summary, err := s.Model.Query(ctx, query)
+			if err != nil {
+				return err
+			}
+			summaries[i] = strings.TrimSpace(summary)
+			return nil
+		})
+	}
+	if err := group.Wait(); err != nil {
+		return nil, err
+	}
+	return summaries, nil
+}
+
+func (s *DiffSummarizer) logf(format string, args ...any) {
+	if s.Log != nil {
+		s.Log(format, args...)
+	}
+}
+
+// chunkFiles lists the files touched by a chunk of diff
+func chunkFiles(chunk string) []string {
+	var paths []string
+	for _, file := range parseDiff(chunk) {
+		if file.Path != "" {
+			paths = append(paths, file.Path)
+		}
+	}
+	return paths
+}
+
+// chunkText packs texts into chunks of at most budget tokens
+func chunkText(texts []string, budget int) []string {
+	var chunks []string
+	var current strings.Builder
+	for _, text := range texts {
+		text = truncateTokens(text, budget)
+		if current.Len() > 0 && estimateTokens(current.String())+estimateTokens(text) > budget {
+			chunks = append(chunks, current.String())
+			current.Reset()
+		}
+		current.WriteString(text)
+		current.WriteString("\n\n")
+	}
+	if current.Len() > 0 {
+		chunks = append(chunks, current.String())
+	}
+	return chunks
+}
@@ -0,0 +1,131 @@
+package aigit
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"unicode/utf8"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+// fileDiff builds a diff of a single file with the given number of added lines per hunk
+func fileDiff(path string, hunks, lines int) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "diff --git a/%s b/%s\nindex 1111111..2222222 100644\n--- a/%s\n+++ b/%s\n", path, path, path, path)
+	for h := 0; h < hunks; h++ {
+		fmt.Fprintf(&b, "@@ -%d,0 +%d,%d @@\n", h*100, h*100, lines)
+		for l := 0; l < lines; l++ {
+			fmt.Fprintf(&b, "+line %d of hunk %d in %s\n", l, h, path)
+		}
+	}
+	return b.String()
+}
+
+var _ = Describe("Diff", func() {
+	Describe("parseDiff", func() {
+		It("should split files and hunks", func() {
+			diff := fileDiff("a.go", 2, 1) + fileDiff("dir/b.go", 1, 1)
+			files := parseDiff(diff)
+			Expect(files).To(HaveLen(2))
+			Expect(files[0].Path).To(Equal("a.go"))
+			Expect(files[0].Hunks).To(HaveLen(2))
+			Expect(files[1].Path).To(Equal("dir/b.go"))
+			Expect(files[0].String() + files[1].String()).To(Equal(diff))
+		})
+	})
+
+	Describe("diffStat", func() {
+		It("should count added and removed lines per file", func() {
+			diff := fileDiff("a.go", 2, 3) + "diff --git a/b.go b/b.go\n--- a/b.go\n+++ b/b.go\n@@ -1,2 +1 @@\n-old\n-gone\n+new\n"
+			Expect(diffStat(diff)).To(Equal("a.go | +6 -0\nb.go | +1 -2\n2 files changed, 7 insertions(+), 2 deletions(-)\n"))
+		})
+	})
+
+	Describe("numberDiffLines", func() {
+		It("should number context and added lines by their line in the new file", func() {
+			diff := "diff --git a/b.go b/b.go\n--- a/b.go\n+++ b/b.go\n@@ -10,3 +12,3 @@ func f() {\n ctx\n-old\n+new\n ctx\n\\ No newline at end of file\n"
+			Expect(numberDiffLines(diff)).To(Equal("diff --git a/b.go b/b.go\n--- a/b.go\n+++ b/b.go\n@@ -10,3 +12,3 @@ func f() {\n" +
+				"   12  ctx\n      -old\n   13 +new\n   14  ctx\n\\ No newline at end of file\n"))
+		})
+	})
+
+	Describe("diffPositions", func() {
+		It("should count positions across hunks of each file", func() {
+			diff := "diff --git a/a.go b/a.go\n--- a/a.go\n+++ b/a.go\n" +
+				"@@ -1,2 +1,2 @@\n ctx\n-old\n+new\n" +
+				"@@ -10,1 +10,2 @@\n ctx\n+added\n" +
+				"diff --git a/b.go b/b.go\n--- a/b.go\n+++ b/b.go\n@@ -0,0 +1 @@\n+first\n"
+			Expect(diffPositions(diff)).To(Equal(map[string]map[int]int{
+				"a.go": {1: 1, 2: 3, 10: 5, 11: 6},
+				"b.go": {1: 1},
+			}))
+		})
+	})
+
+	Describe("chunkDiff", func() {
+		It("should keep small files together", func() {
+			files := parseDiff(fileDiff("a.go", 1, 2) + fileDiff("b.go", 1, 2))
+			Expect(chunkDiff(files, 1000)).To(HaveLen(1))
+		})
+
+		It("should split large files between hunks and repeat the header", func() {
+			files := parseDiff(fileDiff("big.go", 4, 20))
+			chunks := chunkDiff(files, 300)
+			Expect(len(chunks)).To(BeNumerically(">", 1))
+			for _, chunk := range chunks {
+				Expect(chunk).To(HavePrefix("diff --git a/big.go b/big.go"))
+				Expect(estimateTokens(chunk)).To(BeNumerically("<=", 300))
+			}
+		})
+	})
+
+	Describe("truncateTokens", func() {
+		It("should cut on a rune boundary", func() {
+			truncated := truncateTokens("abcé and more", 1)
+			Expect(utf8.ValidString(truncated)).To(BeTrue())
+			Expect(truncated).To(HavePrefix("abc\n"))
+		})
+	})
+
+	Describe("DiffSummarizer", func() {
+		var (
+			calls      atomic.Int32
+			summarizer *DiffSummarizer
+		)
+
+		BeforeEach(func() {
+			calls.Store(0)
+			summarizer = &DiffSummarizer{
+				Model: &mockModel{queryFunc: func(ctx context.Context, query string) (string, error) {
+					n := calls.Add(1)
+					Expect(query).To(ContainSubstring("one part of a larger set of changes"))
+					return fmt.Sprintf("- summary %d", n), nil
+				}},
+				Prompts:     NewPrompts(""),
+				Budget:      200,
+				Parallelism: 2,
+			}
+		})
+
+		It("should pass small diffs through", func() {
+			diff := fileDiff("a.go", 1, 1)
+			out, summarized, err := summarizer.Summarize(context.Background(), diff)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(summarized).To(BeFalse())
+			Expect(out).To(Equal(diff))
+			Expect(calls.Load()).To(BeZero())
+		})
+
+		It("should summarize large diffs chunk by chunk", func() {
+			diff := fileDiff("a.go", 2, 15) + fileDiff("b.go", 2, 15)
+			out, summarized, err := summarizer.Summarize(context.Background(), diff)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(summarized).To(BeTrue())
+			Expect(calls.Load()).To(BeNumerically(">", 1))
+			Expect(strings.Count(out, "- summary")).To(Equal(int(calls.Load())))
+		})
+	})
+})
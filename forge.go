@@ -0,0 +1,239 @@
+package aigit
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+)
+
+const (
+	ForgeAuto   = "auto"
+	ForgeGitHub = "github"
+	ForgeGitLab = "gitlab"
+	ForgeGitea  = "gitea"
+)
+
+// PullRequest holds everything needed to open or update a pull request
+type PullRequest struct {
+	Title       string
+	Description string
+	// Base is the branch to merge into. When editing, an empty base leaves it unchanged.
+	Base string
+	// Draft opens the pull request as a draft. It is ignored when editing.
+	Draft     bool
+	Reviewers []string
+	// TeamReviewers are teams in org/team form
+	TeamReviewers []string
+	Labels        []string
+	Assignees     []string
+	Milestone     string
+}
+
+// Forge is a code hosting service that pull requests are opened on.
+// GitLab calls them merge requests.
+type Forge interface {
+	// CreatePullRequest opens a pull request for the current branch
+	CreatePullRequest(pr PullRequest) error
+	// EditPullRequest updates the open pull request of the current branch
+	EditPullRequest(pr PullRequest) error
+	// HasOpenPullRequest reports whether the current branch has an open pull request
+	HasOpenPullRequest() (bool, error)
+	// GetPullRequestBase returns the base branch of the open pull request of the current branch,
+	// or an empty string if there is none
+	GetPullRequestBase() (string, error)
+	// DescribePullRequest describes how the pull request would be created or edited, for dry runs
+	DescribePullRequest(pr PullRequest, editing bool) string
+	// GetCurrentUser returns the username of the authenticated user, who authors pull requests
+	GetCurrentUser() (string, error)
+}
+
+var (
+	// ErrNoPullRequest is returned when the current branch has no open pull request to act on
+	ErrNoPullRequest      = errors.New("the current branch has no open pull request")
+	ErrReviewsUnsupported = errors.New("reviewing pull requests is only supported on GitHub")
+)
+
+// ReviewComment is an inline comment of a pull request review. Position counts the lines of the
+// file's diff, starting at 1 below its first hunk header.
+type ReviewComment struct {
+	Path     string `json:"path"`
+	Position int    `json:"position"`
+	Body     string `json:"body"`
+}
+
+// Review is a pull request review, encoded as the GitHub API expects it. Without an event the
+// review is created pending, to be submitted by its author.
+type Review struct {
+	// CommitID is the head commit the comment positions refer to
+	CommitID string          `json:"commit_id,omitempty"`
+	Body     string          `json:"body,omitempty"`
+	Comments []ReviewComment `json:"comments"`
+}
+
+// PullRequestReviewer is implemented by forges that can review pull requests
+type PullRequestReviewer interface {
+	// GetPullRequestDiff returns the diff of the open pull request of the current branch, and
+	// the head commit it was taken at
+	GetPullRequestDiff() (diff, commit string, err error)
+	// CreateReview adds a pending review to the open pull request of the current branch
+	CreateReview(review Review) error
+}
+
+// ForgeFactory creates the forge hosting a repository
+type ForgeFactory func(config *Config, git Git) (Forge, error)
+
+// describePullRequest lists the fields of a pull request below a summary line, for forges
+// that do not shell out to a command that could be printed instead
+func describePullRequest(summary string, pr PullRequest, editing bool) string {
+	lines := []string{summary, "title: " + pr.Title}
+	if pr.Base != "" {
+		lines = append(lines, "base: "+pr.Base)
+	}
+	if pr.Draft && !editing {
+		lines = append(lines, "draft: true")
+	}
+	if len(pr.Reviewers) > 0 {
+		lines = append(lines, "reviewers: "+strings.Join(pr.Reviewers, ","))
+	}
+	if len(pr.TeamReviewers) > 0 {
+		lines = append(lines, "team reviewers: "+strings.Join(pr.TeamReviewers, ","))
+	}
+	if len(pr.Labels) > 0 {
+		lines = append(lines, "labels: "+strings.Join(pr.Labels, ","))
+	}
+	if len(pr.Assignees) > 0 {
+		lines = append(lines, "assignees: "+strings.Join(pr.Assignees, ","))
+	}
+	if pr.Milestone != "" {
+		lines = append(lines, "milestone: "+pr.Milestone)
+	}
+	return strings.Join(lines, "\n    ")
+}
+
+// RemoteURL is the location of a repository on a forge, parsed from a git remote URL
+type RemoteURL struct {
+	// Host is the host name, without port
+	Host string
+	// Path is the repository path without the .git suffix, such as group/subgroup/project
+	Path string
+}
+
+// ParseRemoteURL parses https, ssh and scp-style (git@host:path) remote URLs
+func ParseRemoteURL(raw string) (RemoteURL, error) {
+	raw = strings.TrimSpace(raw)
+	var host, path string
+	if strings.Contains(raw, "://") {
+		u, err := url.Parse(raw)
+		if err != nil {
+			return RemoteURL{}, fmt.Errorf("invalid remote URL %q: %w", raw, err)
+		}
+		host, path = u.Hostname(), u.Path
+	} else if i := strings.Index(raw, ":"); i > 0 && !strings.Contains(raw[:i], "/") {
+		host, path = raw[:i], raw[i+1:]
+		if at := strings.LastIndex(host, "@"); at >= 0 {
+			host = host[at+1:]
+		}
+	}
+	path = strings.TrimSuffix(strings.Trim(path, "/"), ".git")
+	if host == "" || path == "" {
+		return RemoteURL{}, fmt.Errorf("unsupported remote URL %q", raw)
+	}
+	return RemoteURL{Host: strings.ToLower(host), Path: path}, nil
+}
+
+// NewForge creates the forge hosting the origin remote. forge.type selects one explicitly,
+// otherwise it is detected from the remote host, falling back to GitHub. GitHub is used through
+// its API when github.token is set and the remote is on github.com or a host in github.hosts,
+// and through the GitHub CLI otherwise, so the token is never sent to other hosts. gitlab.token
+// is likewise only sent to gitlab.com and the hosts in gitlab.hosts, and gitea.token only to the
+// hosts in gitea.hosts, where even codeberg.org has to be listed.
+func NewForge(config *Config, git Git) (Forge, error) {
+	var remote RemoteURL
+	raw, err := git.GetRemoteURL("origin")
+	if err == nil {
+		remote, err = ParseRemoteURL(raw)
+	}
+
+	kind := config.Get("forge.type")
+	if kind == ForgeAuto {
+		kind = ForgeGitHub
+		if err == nil {
+			kind = detectForge(config, remote.Host)
+		}
+	}
+	if kind == ForgeGitHub {
+		if err != nil || config.Get("github.token") == "" {
+			// gh finds the repository itself
+			return NewGitHub()
+		}
+		return newGitHubForge(config, remote, git)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("error reading the origin remote: %w", err)
+	}
+	baseURL := config.Get("forge.url")
+	if baseURL == "" {
+		baseURL = "https://" + remote.Host
+	}
+	switch kind {
+	case ForgeGitLab:
+		token, err := forgeToken(config, ForgeGitLab, remote.Host, "gitlab.com")
+		if err != nil {
+			return nil, err
+		}
+		return NewGitLab(baseURL, token, remote.Path, git), nil
+	case ForgeGitea:
+		token, err := forgeToken(config, ForgeGitea, remote.Host)
+		if err != nil {
+			return nil, err
+		}
+		return NewGitea(baseURL, token, remote.Path, git), nil
+	default:
+		return nil, fmt.Errorf("unknown forge type %q", kind)
+	}
+}
+
+// newGitHubForge uses the GitHub API for github.com and the GitHub Enterprise Server hosts listed
+// in github.hosts, and the GitHub CLI with its own credentials for any other host
+func newGitHubForge(config *Config, remote RemoteURL, git Git) (Forge, error) {
+	if remote.Host == "github.com" {
+		return NewGitHubAPI(DefaultGitHubAPIURL, config.Get("github.token"), remote.Path, git), nil
+	}
+	if !slices.Contains(config.List("github.hosts"), remote.Host) {
+		return NewGitHub()
+	}
+	baseURL := config.Get("forge.url")
+	if baseURL == "" {
+		baseURL = "https://" + remote.Host
+	}
+	// GitHub Enterprise Server serves the API below /api/v3
+	return NewGitHubAPI(strings.TrimSuffix(baseURL, "/")+"/api/v3", config.Get("github.token"), remote.Path, git), nil
+}
+
+// forgeToken returns the <kind>.token setting for a remote host, which it may only be sent to
+// if the host is one of the public instances or listed in <kind>.hosts. Repositories choose
+// their remote, so other hosts are refused rather than handed the token.
+func forgeToken(config *Config, kind, host string, public ...string) (string, error) {
+	token := config.Get(kind + ".token")
+	if token == "" || slices.Contains(public, host) || slices.Contains(config.List(kind+".hosts"), host) {
+		return token, nil
+	}
+	return "", fmt.Errorf("%s.token is not sent to %s, add it to %s.hosts in the user config to trust it", kind, host, kind)
+}
+
+// detectForge guesses the forge running on a host. GitLab and Gitea are recognized by their
+// public instances, by host names starting with the product name, and by the self-hosted
+// instances listed in gitlab.hosts and gitea.hosts.
+func detectForge(config *Config, host string) string {
+	switch {
+	case host == "gitlab.com" || strings.HasPrefix(host, "gitlab.") || slices.Contains(config.List("gitlab.hosts"), host):
+		return ForgeGitLab
+	case host == "codeberg.org" || strings.HasPrefix(host, "gitea.") || strings.HasPrefix(host, "forgejo.") || slices.Contains(config.List("gitea.hosts"), host):
+		return ForgeGitea
+	default:
+		return ForgeGitHub
+	}
+}
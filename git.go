@@ -4,11 +4,52 @@ import (
 	"errors"
 	"fmt"
 	"os/exec"
+	"strconv"
 	"strings"
 )
 
 var ErrNoGit = errors.New("git is not installed or not found in PATH")
 
+// PushFailure classifies why a push failed
+type PushFailure int
+
+const (
+	PushFailed PushFailure = iota
+	// PushRejected means the remote has commits the local branch lacks, e.g. after a rebase
+	PushRejected
+	// PushAuth means the remote refused our credentials
+	PushAuth
+	// PushNetwork means the remote could not be reached
+	PushNetwork
+)
+
+func (f PushFailure) String() string {
+	switch f {
+	case PushRejected:
+		return "rejected"
+	case PushAuth:
+		return "authentication failed"
+	case PushNetwork:
+		return "network error"
+	default:
+		return "failed"
+	}
+}
+
+// PushError is returned when a push fails, classified by cause
+type PushError struct {
+	Reason PushFailure
+	Err    error
+}
+
+func (e *PushError) Error() string {
+	return fmt.Sprintf("push %s: %v", e.Reason, e.Err)
+}
+
+func (e *PushError) Unwrap() error {
+	return e.Err
+}
+
 // Git defines the interface for git operations
 type Git interface {
 	// GetStagedDiff returns the output of `git diff --staged` command
@@ -17,16 +58,56 @@ type Git interface {
 	Commit(message string) error
 	// GetCurrentBranch returns the name of the current branch
 	GetCurrentBranch() (string, error)
-	// GetBaseBranch returns the name of the base branch (main/master)
+	// GetBaseBranch returns the name of the branch the current branch is based on: its
+	// upstream if that is another branch, else the default branch of origin
 	GetBaseBranch() (string, error)
-	// GetCommitHistory returns the commit history between current branch and base branch
+	// GetCommitHistory returns the full messages of the commits on the current branch since it
+	// forked from the base branch
 	GetCommitHistory(baseBranch string) (string, error)
+	// GetBranchDiff returns the changes on the current branch since it forked from the base
+	// branch, like git diff base...HEAD
+	GetBranchDiff(baseBranch string) (string, error)
 	// Push pushes the current branch to remote
 	Push() error
-	// ForcePush force pushes the current branch to remote
-	ForcePush() error
+	// ForcePushWithLease force pushes the current branch to remote, but only if
+	// the remote branch is still at expectedSHA
+	ForcePushWithLease(expectedSHA string) error
+	// GetRemoteBranchSHA returns the last fetched commit of the current branch on remote
+	GetRemoteBranchSHA() (string, error)
+	// IsAncestor reports whether a commit is HEAD or one of its ancestors, so that pushing
+	// HEAD over it fast-forwards
+	IsAncestor(sha string) (bool, error)
 	// Amend amends the last commit
 	Amend(message string) error
+	// GetStagedFiles returns the paths of all staged files
+	GetStagedFiles() ([]string, error)
+	// GetRecentCommits returns the subjects of the last n commits, newest first
+	GetRecentCommits(n int) ([]string, error)
+	// GetRemoteURL returns the fetch URL of a remote
+	GetRemoteURL(name string) (string, error)
+}
+
+// baseBranchCandidates are common base branch names, tried when origin has no default branch
+var baseBranchCandidates = []string{"main", "master", "develop", "trunk"}
+
+const (
+	GitBackendCli   = "cli"
+	GitBackendGoGit = "go-git"
+)
+
+// GitFactory creates the git implementation for a git.backend setting
+type GitFactory func(backend string) (Git, error)
+
+// NewGitBackend creates the Git implementation selected by the git.backend setting
+func NewGitBackend(backend string) (Git, error) {
+	switch backend {
+	case GitBackendCli, "":
+		return NewGit()
+	case GitBackendGoGit:
+		return NewGoGit()
+	default:
+		return nil, fmt.Errorf("unknown git backend %q", backend)
+	}
 }
 
 // GitCli implements Git interface using actual git commands
@@ -46,31 +127,91 @@ func (g *GitCli) GetStagedDiff() (string, error) {
 }
 
 func (g *GitCli) Commit(message string) error {
-	_, err := runCommand("git", "commit", "-m", message)
+	_, err := runCommand("git", commitArgs(message)...)
 	return err
 }
 
+// commitArgs returns the git arguments used to commit with a message
+func commitArgs(message string) []string {
+	return []string{"commit", "-m", message}
+}
+
+// amendArgs returns the git arguments used to amend the last commit, in order
+func amendArgs(message string) [][]string {
+	return [][]string{
+		{"commit", "--amend", "--no-edit"},
+		{"commit", "--amend", "-m", message},
+	}
+}
+
 func (g *GitCli) GetCurrentBranch() (string, error) {
 	return runCommand("git", "rev-parse", "--abbrev-ref", "HEAD")
 }
 
 func (g *GitCli) GetBaseBranch() (string, error) {
-	// Try main first, then master
-	for _, branch := range []string{"main", "master"} {
-		cmd := exec.Command("git", "show-ref", "--verify", "--quiet", "refs/heads/"+branch)
-		if err := cmd.Run(); err == nil {
-			return branch, nil
+	branch, err := g.GetCurrentBranch()
+	if err != nil {
+		return "", fmt.Errorf("could not get current branch: %w", err)
+	}
+	branch = strings.TrimSpace(branch)
+
+	// A branch tracking another branch, e.g. one created from origin/develop, targets it
+	if merge, err := runCommand("git", "config", "--get", "branch."+branch+".merge"); err == nil {
+		if upstream := strings.TrimPrefix(strings.TrimSpace(merge), "refs/heads/"); upstream != branch {
+			return upstream, nil
+		}
+	}
+
+	// Otherwise target the default branch of the remote
+	if head, err := runCommand("git", "symbolic-ref", "--quiet", "refs/remotes/origin/HEAD"); err == nil {
+		return strings.TrimPrefix(strings.TrimSpace(head), "refs/remotes/origin/"), nil
+	}
+
+	for _, base := range baseBranchCandidates {
+		for _, ref := range []string{"refs/remotes/origin/" + base, "refs/heads/" + base} {
+			cmd := exec.Command("git", "show-ref", "--verify", "--quiet", ref)
+			if err := cmd.Run(); err == nil {
+				return base, nil
+			}
 		}
 	}
-	return "", fmt.Errorf("could not find main or master branch")
+	return "", fmt.Errorf("could not detect the base branch, use --base to set it")
 }
 
 func (g *GitCli) GetCommitHistory(baseBranch string) (string, error) {
-	return runCommand("git", "log", "--pretty=format:%h %s", baseBranch+"..HEAD")
+	mergeBase, err := g.mergeBase(baseBranch)
+	if err != nil {
+		return "", err
+	}
+	// Full messages, with the body indented below the subject
+	return runCommand("git", "log", "--pretty=format:%h %s%n%w(0,2,2)%b", mergeBase+"..HEAD")
+}
+
+func (g *GitCli) GetBranchDiff(baseBranch string) (string, error) {
+	mergeBase, err := g.mergeBase(baseBranch)
+	if err != nil {
+		return "", err
+	}
+	return runCommand("git", "diff", mergeBase, "HEAD")
+}
+
+// mergeBase returns the commit where the current branch forked from the base branch
+func (g *GitCli) mergeBase(baseBranch string) (string, error) {
+	// Prefer the remote branch, since the local one may be stale or missing
+	ref := baseBranch
+	cmd := exec.Command("git", "show-ref", "--verify", "--quiet", "refs/remotes/origin/"+baseBranch)
+	if err := cmd.Run(); err == nil {
+		ref = "refs/remotes/origin/" + baseBranch
+	}
+	mergeBase, err := runCommand("git", "merge-base", "HEAD", ref)
+	if err != nil {
+		return "", fmt.Errorf("no common history with %s: %w", baseBranch, err)
+	}
+	return strings.TrimSpace(mergeBase), nil
 }
 
 func (g *GitCli) Push() error {
-	output, err := runCommand("git", "push")
+	output, err := runCommand("git", pushArgs()...)
 	if err != nil && isNoUpstreamError(output) {
 		branch, berr := g.GetCurrentBranch()
 		if berr != nil {
@@ -79,30 +220,70 @@ func (g *GitCli) Push() error {
 		branch = strings.TrimSpace(branch)
 		output, err = runCommand("git", "push", "--set-upstream", "origin", branch)
 	}
-	return err
+	return pushError(output, err)
 }
 
-func (g *GitCli) ForcePush() error {
-	output, err := runCommand("git", "push", "--force")
-	if err != nil && isNoUpstreamError(output) {
-		branch, berr := g.GetCurrentBranch()
-		if berr != nil {
-			return fmt.Errorf("force push failed and could not get current branch: %w", berr)
-		}
-		branch = strings.TrimSpace(branch)
-		output, err = runCommand("git", "push", "--force", "--set-upstream", "origin", branch)
+// pushArgs returns the git arguments used to push the current branch
+func pushArgs() []string {
+	return []string{"push"}
+}
+
+func (g *GitCli) ForcePushWithLease(expectedSHA string) error {
+	branch, err := g.GetCurrentBranch()
+	if err != nil {
+		return fmt.Errorf("could not get current branch: %w", err)
 	}
-	return err
+	output, err := runCommand("git", forcePushArgs(strings.TrimSpace(branch), expectedSHA)...)
+	return pushError(output, err)
+}
+
+// forcePushArgs returns the git arguments used to force push a branch with a lease on expectedSHA
+func forcePushArgs(branch, expectedSHA string) []string {
+	return []string{"push", "--force-with-lease=refs/heads/" + branch + ":" + expectedSHA, "--set-upstream", "origin", branch}
+}
+
+func (g *GitCli) GetRemoteBranchSHA() (string, error) {
+	branch, err := g.GetCurrentBranch()
+	if err != nil {
+		return "", fmt.Errorf("could not get current branch: %w", err)
+	}
+	output, err := runCommand("git", "rev-parse", "--verify", "refs/remotes/origin/"+strings.TrimSpace(branch))
+	if err != nil {
+		return "", fmt.Errorf("no remote tracking branch for %s, run git fetch first: %w", strings.TrimSpace(branch), err)
+	}
+	return strings.TrimSpace(output), nil
+}
+
+func (g *GitCli) IsAncestor(sha string) (bool, error) {
+	cmd := exec.Command("git", "merge-base", "--is-ancestor", sha, "HEAD")
+	err := cmd.Run()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() == 1 {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("error checking ancestry of %s: %w", sha, err)
+	}
+	return true, nil
+}
+
+func (g *GitCli) GetRemoteURL(name string) (string, error) {
+	output, err := runCommand("git", "remote", "get-url", name)
+	if err != nil {
+		return "", fmt.Errorf("error reading URL of remote %s: %w", name, err)
+	}
+	return strings.TrimSpace(output), nil
 }
 
 func (g *GitCli) Amend(message string) error {
-	cmd := exec.Command("git", "commit", "--amend", "--no-edit")
+	args := amendArgs(message)
+	cmd := exec.Command("git", args[0]...)
 	if err := cmd.Run(); err != nil {
 		return fmt.Errorf("error amending commit: %w", err)
 	}
 
 	// Update the commit message
-	cmd = exec.Command("git", "commit", "--amend", "-m", message)
+	cmd = exec.Command("git", args[1]...)
 	if err := cmd.Run(); err != nil {
 		return fmt.Errorf("error updating commit message: %w", err)
 	}
@@ -110,6 +291,67 @@ func (g *GitCli) Amend(message string) error {
 	return nil
 }
 
+func (g *GitCli) GetStagedFiles() ([]string, error) {
+	output, err := runCommand("git", "diff", "--staged", "--name-only")
+	if err != nil {
+		return nil, err
+	}
+	return splitLines(output), nil
+}
+
+func (g *GitCli) GetRecentCommits(n int) ([]string, error) {
+	output, err := runCommand("git", "log", "-n", strconv.Itoa(n), "--pretty=format:%s")
+	if err != nil {
+		return nil, err
+	}
+	return splitLines(output), nil
+}
+
+// splitLines splits command output into non-empty lines
+func splitLines(output string) []string {
+	var lines []string
+	for _, line := range strings.Split(output, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+// pushError wraps a failed push in a PushError classified from the command output
+func pushError(output string, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &PushError{Reason: classifyPushFailure(output), Err: err}
+}
+
+// classifyPushFailure determines why a push failed from git's output
+func classifyPushFailure(output string) PushFailure {
+	output = strings.ToLower(output)
+	switch {
+	case containsAny(output, "[rejected]", "non-fast-forward", "fetch first", "stale info"):
+		return PushRejected
+	case containsAny(output, "authentication failed", "permission denied", "could not read username",
+		"invalid username or password", "the requested url returned error: 403"):
+		return PushAuth
+	case containsAny(output, "could not resolve host", "connection timed out", "connection refused",
+		"network is unreachable", "operation timed out", "could not read from remote repository"):
+		return PushNetwork
+	default:
+		return PushFailed
+	}
+}
+
+func containsAny(s string, substrings ...string) bool {
+	for _, sub := range substrings {
+		if strings.Contains(s, sub) {
+			return true
+		}
+	}
+	return false
+}
+
 // isNoUpstreamError checks if the output indicates a missing upstream branch
 func isNoUpstreamError(output string) bool {
 	return strings.Contains(output, "has no upstream branch")
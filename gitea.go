@@ -0,0 +1,326 @@
+package aigit
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// giteaPageSize is the number of items requested per page of a list
+const giteaPageSize = 50
+
+// giteaWIPPrefixes are the title prefixes Gitea marks pull requests as work in progress by
+var giteaWIPPrefixes = []string{"WIP:", "[WIP]"}
+
+// Gitea implements the Forge interface for Gitea and Forgejo, using their REST API
+type Gitea struct {
+	api     *restClient
+	baseURL string
+	repo    string
+	git     Git
+}
+
+// NewGitea creates a forge for the repository at the given owner/repo path on the
+// Gitea or Forgejo instance at baseURL
+func NewGitea(baseURL, token, repo string, git Git) *Gitea {
+	baseURL = strings.TrimSuffix(baseURL, "/")
+	api := newRestClient(baseURL + "/api/v1")
+	if token != "" {
+		api.header.Set("Authorization", "token "+token)
+	}
+	return &Gitea{
+		api:     api,
+		baseURL: baseURL,
+		repo:    repo,
+		git:     git,
+	}
+}
+
+type giteaBranch struct {
+	Ref string `json:"ref"`
+	// Repo is the repository holding the branch, missing if it was deleted
+	Repo *giteaRepository `json:"repo"`
+}
+
+type giteaRepository struct {
+	FullName string `json:"full_name"`
+}
+
+type giteaLabel struct {
+	ID   int64  `json:"id"`
+	Name string `json:"name"`
+}
+
+type giteaPullRequest struct {
+	Number    int          `json:"number"`
+	Title     string       `json:"title"`
+	HTMLURL   string       `json:"html_url"`
+	Base      giteaBranch  `json:"base"`
+	Head      giteaBranch  `json:"head"`
+	Labels    []giteaLabel `json:"labels"`
+	Assignees []giteaUser  `json:"assignees"`
+}
+
+type giteaUser struct {
+	Login string `json:"login"`
+}
+
+type giteaPullRequestRequest struct {
+	Head      string   `json:"head,omitempty"`
+	Base      string   `json:"base,omitempty"`
+	Title     string   `json:"title"`
+	Body      string   `json:"body"`
+	Assignees []string `json:"assignees,omitempty"`
+	Labels    []int64  `json:"labels,omitempty"`
+	Milestone int64    `json:"milestone,omitempty"`
+}
+
+type giteaReviewRequest struct {
+	Reviewers     []string `json:"reviewers,omitempty"`
+	TeamReviewers []string `json:"team_reviewers,omitempty"`
+}
+
+func (g *Gitea) CreatePullRequest(pr PullRequest) error {
+	branch, err := g.currentBranch()
+	if err != nil {
+		return err
+	}
+
+	base := pr.Base
+	if base == "" {
+		var repo struct {
+			DefaultBranch string `json:"default_branch"`
+		}
+		if err := g.api.request(http.MethodGet, g.repoPath(), nil, &repo); err != nil {
+			return fmt.Errorf("error reading the default branch: %w", err)
+		}
+		base = repo.DefaultBranch
+	}
+
+	title := pr.Title
+	if pr.Draft {
+		title = giteaWIPPrefixes[0] + " " + title
+	}
+	req := giteaPullRequestRequest{
+		Head:  branch,
+		Base:  base,
+		Title: title,
+		Body:  pr.Description,
+	}
+	if err := g.resolveMetadata(pr, &req, nil); err != nil {
+		return err
+	}
+
+	var created giteaPullRequest
+	if err := g.api.request(http.MethodPost, g.repoPath()+"/pulls", req, &created); err != nil {
+		return fmt.Errorf("failed to create pull request: %w", err)
+	}
+	if err := g.requestReviews(created.Number, pr); err != nil {
+		return err
+	}
+	fmt.Println(created.HTMLURL)
+	return nil
+}
+
+func (g *Gitea) EditPullRequest(pr PullRequest) error {
+	existing, err := g.openPullRequest()
+	if err != nil {
+		return err
+	}
+	if existing == nil {
+		return fmt.Errorf("no open pull request to edit")
+	}
+
+	// Keep the work in progress status, which Gitea derives from the title
+	title := pr.Title
+	for _, prefix := range giteaWIPPrefixes {
+		if strings.HasPrefix(strings.ToUpper(existing.Title), prefix) {
+			title = existing.Title[:len(prefix)] + " " + title
+			break
+		}
+	}
+	req := giteaPullRequestRequest{
+		Base:  pr.Base,
+		Title: title,
+		Body:  pr.Description,
+	}
+	if err := g.resolveMetadata(pr, &req, existing); err != nil {
+		return err
+	}
+
+	var updated giteaPullRequest
+	if err := g.api.request(http.MethodPatch, g.repoPath()+"/pulls/"+strconv.Itoa(existing.Number), req, &updated); err != nil {
+		return fmt.Errorf("failed to edit pull request: %w", err)
+	}
+	if err := g.requestReviews(existing.Number, pr); err != nil {
+		return err
+	}
+	fmt.Println(updated.HTMLURL)
+	return nil
+}
+
+// resolveMetadata looks up the ids of labels and the milestone. Gitea replaces labels and
+// assignees on update, so those of an existing pull request are kept.
+func (g *Gitea) resolveMetadata(pr PullRequest, req *giteaPullRequestRequest, existing *giteaPullRequest) error {
+	if len(pr.Labels) > 0 {
+		ids, err := g.labelIDs(pr.Labels)
+		if err != nil {
+			return err
+		}
+		if existing != nil {
+			for _, label := range existing.Labels {
+				if !slices.Contains(ids, label.ID) {
+					ids = append(ids, label.ID)
+				}
+			}
+		}
+		req.Labels = ids
+	}
+	if len(pr.Assignees) > 0 {
+		req.Assignees = nil
+		if existing != nil {
+			for _, user := range existing.Assignees {
+				req.Assignees = append(req.Assignees, user.Login)
+			}
+		}
+		req.Assignees = appendMissing(req.Assignees, pr.Assignees...)
+	}
+	if pr.Milestone != "" {
+		var milestones []struct {
+			ID    int64  `json:"id"`
+			Title string `json:"title"`
+		}
+		path := g.repoPath() + "/milestones?state=open&name=" + url.QueryEscape(pr.Milestone)
+		if err := g.api.request(http.MethodGet, path, nil, &milestones); err != nil {
+			return fmt.Errorf("error looking up milestone %s: %w", pr.Milestone, err)
+		}
+		for _, milestone := range milestones {
+			if milestone.Title == pr.Milestone {
+				req.Milestone = milestone.ID
+			}
+		}
+		if req.Milestone == 0 {
+			return fmt.Errorf("milestone %s not found", pr.Milestone)
+		}
+	}
+	return nil
+}
+
+// labelIDs looks up the ids of labels by name
+func (g *Gitea) labelIDs(names []string) ([]int64, error) {
+	byName := map[string]int64{}
+	for page := 1; ; page++ {
+		var labels []giteaLabel
+		if err := g.api.request(http.MethodGet, g.repoPath()+"/labels"+giteaPage(page), nil, &labels); err != nil {
+			return nil, fmt.Errorf("error listing labels: %w", err)
+		}
+		for _, label := range labels {
+			byName[label.Name] = label.ID
+		}
+		if len(labels) < giteaPageSize {
+			break
+		}
+	}
+
+	ids := make([]int64, 0, len(names))
+	for _, name := range names {
+		id, ok := byName[name]
+		if !ok {
+			return nil, fmt.Errorf("label %s not found", name)
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// requestReviews requests reviews from the reviewers and teams of a pull request. Gitea names
+// teams without their organization, which is the owner of the repository.
+func (g *Gitea) requestReviews(number int, pr PullRequest) error {
+	if len(pr.Reviewers) == 0 && len(pr.TeamReviewers) == 0 {
+		return nil
+	}
+	req := giteaReviewRequest{Reviewers: pr.Reviewers}
+	for _, team := range pr.TeamReviewers {
+		req.TeamReviewers = append(req.TeamReviewers, team[strings.LastIndex(team, "/")+1:])
+	}
+	path := g.repoPath() + "/pulls/" + strconv.Itoa(number) + "/requested_reviewers"
+	if err := g.api.request(http.MethodPost, path, req, nil); err != nil {
+		return fmt.Errorf("failed to request reviews: %w", err)
+	}
+	return nil
+}
+
+func (g *Gitea) HasOpenPullRequest() (bool, error) {
+	pr, err := g.openPullRequest()
+	return pr != nil, err
+}
+
+func (g *Gitea) GetPullRequestBase() (string, error) {
+	pr, err := g.openPullRequest()
+	if err != nil || pr == nil {
+		return "", err
+	}
+	return pr.Base.Ref, nil
+}
+
+func (g *Gitea) GetCurrentUser() (string, error) {
+	var user giteaUser
+	if err := g.api.request(http.MethodGet, "/user", nil, &user); err != nil {
+		return "", fmt.Errorf("error looking up the authenticated user: %w", err)
+	}
+	return user.Login, nil
+}
+
+func (g *Gitea) DescribePullRequest(pr PullRequest, editing bool) string {
+	action := "create a pull request in"
+	if editing {
+		action = "edit the open pull request in"
+	}
+	return describePullRequest(fmt.Sprintf("gitea: %s %s on %s", action, g.repo, g.baseURL), pr, editing)
+}
+
+// openPullRequest returns the open pull request of the current branch, or nil if there is none.
+// The API cannot filter by head branch, so all open pull requests are searched. Pull requests
+// from forks with a branch of the same name are skipped.
+func (g *Gitea) openPullRequest() (*giteaPullRequest, error) {
+	branch, err := g.currentBranch()
+	if err != nil {
+		return nil, err
+	}
+	for page := 1; ; page++ {
+		var prs []giteaPullRequest
+		if err := g.api.request(http.MethodGet, g.repoPath()+"/pulls"+giteaPage(page)+"&state=open", nil, &prs); err != nil {
+			return nil, fmt.Errorf("error looking up pull requests: %w", err)
+		}
+		for i := range prs {
+			head := prs[i].Head
+			if head.Ref == branch && head.Repo != nil && strings.EqualFold(head.Repo.FullName, g.repo) {
+				return &prs[i], nil
+			}
+		}
+		if len(prs) < giteaPageSize {
+			return nil, nil
+		}
+	}
+}
+
+func (g *Gitea) currentBranch() (string, error) {
+	branch, err := g.git.GetCurrentBranch()
+	if err != nil {
+		return "", fmt.Errorf("could not get current branch: %w", err)
+	}
+	return strings.TrimSpace(branch), nil
+}
+
+// repoPath returns the API path of the repository
+func (g *Gitea) repoPath() string {
+	return "/repos/" + g.repo
+}
+
+// giteaPage returns the query selecting a page of a list
+func giteaPage(page int) string {
+	return fmt.Sprintf("?page=%d&limit=%d", page, giteaPageSize)
+}
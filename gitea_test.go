@@ -0,0 +1,167 @@
+package aigit
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Gitea", func() {
+	var (
+		server    *httptest.Server
+		requests  []giteaPullRequestRequest
+		reviewers []giteaReviewRequest
+		open      []giteaPullRequest
+		gitea     *Gitea
+	)
+
+	BeforeEach(func() {
+		requests = nil
+		reviewers = nil
+		open = nil
+		mux := http.NewServeMux()
+		reply := func(w http.ResponseWriter, v any) {
+			w.Header().Set("Content-Type", "application/json")
+			Expect(json.NewEncoder(w).Encode(v)).To(Succeed())
+		}
+		record := func(w http.ResponseWriter, r *http.Request) {
+			var req giteaPullRequestRequest
+			Expect(json.NewDecoder(r.Body).Decode(&req)).To(Succeed())
+			requests = append(requests, req)
+			reply(w, giteaPullRequest{Number: 5, HTMLURL: "https://forgejo.test/acme/api/pulls/5"})
+		}
+		mux.HandleFunc("GET /api/v1/repos/acme/api", func(w http.ResponseWriter, r *http.Request) {
+			reply(w, map[string]string{"default_branch": "trunk"})
+		})
+		mux.HandleFunc("GET /api/v1/repos/acme/api/pulls", func(w http.ResponseWriter, r *http.Request) {
+			Expect(r.URL.Query().Get("state")).To(Equal("open"))
+			// Serve a full first page of other branches to exercise paging
+			if r.URL.Query().Get("page") == "1" {
+				page := make([]giteaPullRequest, giteaPageSize)
+				for i := range page {
+					page[i] = giteaPullRequest{Number: 100 + i, Head: giteaBranch{Ref: "other-" + strconv.Itoa(i)}}
+				}
+				reply(w, page)
+				return
+			}
+			reply(w, open)
+		})
+		mux.HandleFunc("POST /api/v1/repos/acme/api/pulls", record)
+		mux.HandleFunc("PATCH /api/v1/repos/acme/api/pulls/5", record)
+		mux.HandleFunc("POST /api/v1/repos/acme/api/pulls/5/requested_reviewers", func(w http.ResponseWriter, r *http.Request) {
+			var req giteaReviewRequest
+			Expect(json.NewDecoder(r.Body).Decode(&req)).To(Succeed())
+			reviewers = append(reviewers, req)
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte("[]"))
+		})
+		mux.HandleFunc("GET /api/v1/user", func(w http.ResponseWriter, r *http.Request) {
+			reply(w, giteaUser{Login: "carol"})
+		})
+		mux.HandleFunc("GET /api/v1/repos/acme/api/labels", func(w http.ResponseWriter, r *http.Request) {
+			reply(w, []giteaLabel{{ID: 11, Name: "bug"}, {ID: 12, Name: "api"}})
+		})
+		mux.HandleFunc("GET /api/v1/repos/acme/api/milestones", func(w http.ResponseWriter, r *http.Request) {
+			Expect(r.URL.Query().Get("name")).To(Equal("v1.0"))
+			reply(w, []map[string]any{{"id": 7, "title": "v1.0"}})
+		})
+
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("Authorization") != "token secret" {
+				w.WriteHeader(http.StatusUnauthorized)
+				w.Write([]byte(`{"message":"user does not exist","url":"https://forgejo.test/api/swagger"}`))
+				return
+			}
+			mux.ServeHTTP(w, r)
+		}))
+		DeferCleanup(server.Close)
+
+		git := &mockGit{getCurrentBranchFunc: func() (string, error) { return "feature\n", nil }}
+		gitea = NewGitea(server.URL+"/", "secret", "acme/api", git)
+	})
+
+	It("should create a work in progress pull request and request reviews", func() {
+		err := gitea.CreatePullRequest(PullRequest{
+			Title:         "Add thing",
+			Description:   "Adds a thing.",
+			Draft:         true,
+			Reviewers:     []string{"alice"},
+			TeamReviewers: []string{"acme/core"},
+			Labels:        []string{"api"},
+			Assignees:     []string{"bob"},
+			Milestone:     "v1.0",
+		})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(requests).To(Equal([]giteaPullRequestRequest{{
+			Head:      "feature",
+			Base:      "trunk",
+			Title:     "WIP: Add thing",
+			Body:      "Adds a thing.",
+			Assignees: []string{"bob"},
+			Labels:    []int64{12},
+			Milestone: 7,
+		}}))
+		Expect(reviewers).To(Equal([]giteaReviewRequest{{Reviewers: []string{"alice"}, TeamReviewers: []string{"core"}}}))
+	})
+
+	It("should report unknown labels", func() {
+		err := gitea.CreatePullRequest(PullRequest{Title: "Add thing", Base: "main", Labels: []string{"nope"}})
+		Expect(err).To(MatchError(ContainSubstring("label nope not found")))
+		Expect(requests).To(BeEmpty())
+	})
+
+	Context("when the branch has an open pull request", func() {
+		BeforeEach(func() {
+			open = []giteaPullRequest{{
+				// A fork with a branch of the same name
+				Number: 9,
+				Base:   giteaBranch{Ref: "main"},
+				Head:   giteaBranch{Ref: "feature", Repo: &giteaRepository{FullName: "mallory/api"}},
+			}, {
+				Number:    5,
+				Title:     "[WIP] Old title",
+				Base:      giteaBranch{Ref: "release"},
+				Head:      giteaBranch{Ref: "feature", Repo: &giteaRepository{FullName: "acme/api"}},
+				Labels:    []giteaLabel{{ID: 11, Name: "bug"}},
+				Assignees: []giteaUser{{Login: "carol"}},
+			}}
+		})
+
+		It("should report its base", func() {
+			Expect(gitea.HasOpenPullRequest()).To(BeTrue())
+			Expect(gitea.GetPullRequestBase()).To(Equal("release"))
+		})
+
+		It("should keep its work in progress status, labels and assignees", func() {
+			err := gitea.EditPullRequest(PullRequest{Title: "New title", Description: "New.", Labels: []string{"api"}, Assignees: []string{"bob"}})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(requests).To(Equal([]giteaPullRequestRequest{{
+				Title:     "[WIP] New title",
+				Body:      "New.",
+				Assignees: []string{"carol", "bob"},
+				Labels:    []int64{12, 11},
+			}}))
+			Expect(reviewers).To(BeEmpty())
+		})
+	})
+
+	It("should report the authenticated user", func() {
+		Expect(gitea.GetCurrentUser()).To(Equal("carol"))
+	})
+
+	It("should report no open pull request", func() {
+		Expect(gitea.HasOpenPullRequest()).To(BeFalse())
+		Expect(gitea.GetPullRequestBase()).To(BeEmpty())
+	})
+
+	It("should return API errors", func() {
+		gitea = NewGitea(server.URL, "wrong", "acme/api", gitea.git)
+		_, err := gitea.HasOpenPullRequest()
+		Expect(err).To(MatchError(ContainSubstring(fmt.Sprintf("user does not exist (status %d)", http.StatusUnauthorized))))
+	})
+})
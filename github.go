@@ -1,28 +1,20 @@
 package aigit
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
+	"os"
 	"os/exec"
 	"strings"
 )
 
 var ErrNoGitHubCLI = errors.New("GitHub CLI (gh) is not installed or not found in PATH")
 
-// GitHub defines the interface for GitHub operations
-type GitHub interface {
-	// CreatePullRequest creates a pull request with the given title and description
-	CreatePullRequest(title, description string) error
-	// EditPullRequest edits the current pull request with the given title and description
-	EditPullRequest(title, description string) error
-	// HasOpenPullRequest returns true if there is an open PR for the current branch
-	HasOpenPullRequest() (bool, error)
-}
-
-// GitHubCLI implements GitHub interface using the GitHub CLI
+// GitHubCLI implements the Forge interface for GitHub using the GitHub CLI
 type GitHubCLI struct{}
 
-func NewGitHub() (GitHub, error) {
+func NewGitHub() (Forge, error) {
 	// Verify GitHub CLI is installed
 	cmd := exec.Command("gh", "--version")
 	if err := cmd.Run(); err != nil {
@@ -31,8 +23,8 @@ func NewGitHub() (GitHub, error) {
 	return &GitHubCLI{}, nil
 }
 
-func (g *GitHubCLI) CreatePullRequest(title, description string) error {
-	output, err := runCommand("gh", "pr", "create", "--title", title, "--body", description)
+func (g *GitHubCLI) CreatePullRequest(pr PullRequest) error {
+	output, err := runCommand("gh", createPRArgs(pr)...)
 	if err != nil {
 		return fmt.Errorf("failed to create pull request: %w", err)
 	}
@@ -40,8 +32,8 @@ func (g *GitHubCLI) CreatePullRequest(title, description string) error {
 	return nil
 }
 
-func (g *GitHubCLI) EditPullRequest(title, description string) error {
-	output, err := runCommand("gh", "pr", "edit", "--title", title, "--body", description)
+func (g *GitHubCLI) EditPullRequest(pr PullRequest) error {
+	output, err := runCommand("gh", editPRArgs(pr)...)
 	if err != nil {
 		return fmt.Errorf("failed to edit pull request: %w", err)
 	}
@@ -49,6 +41,53 @@ func (g *GitHubCLI) EditPullRequest(title, description string) error {
 	return nil
 }
 
+func (g *GitHubCLI) DescribePullRequest(pr PullRequest, editing bool) string {
+	if editing {
+		return formatCommand("gh", editPRArgs(pr)...)
+	}
+	return formatCommand("gh", createPRArgs(pr)...)
+}
+
+// createPRArgs returns the gh arguments used to create a pull request
+func createPRArgs(pr PullRequest) []string {
+	args := []string{"pr", "create", "--title", pr.Title, "--body", pr.Description}
+	if pr.Base != "" {
+		args = append(args, "--base", pr.Base)
+	}
+	if pr.Draft {
+		args = append(args, "--draft")
+	}
+	return append(args, prMetadataArgs(pr, "--reviewer", "--label", "--assignee")...)
+}
+
+// editPRArgs returns the gh arguments used to edit the pull request of the current branch
+func editPRArgs(pr PullRequest) []string {
+	args := []string{"pr", "edit", "--title", pr.Title, "--body", pr.Description}
+	if pr.Base != "" {
+		args = append(args, "--base", pr.Base)
+	}
+	return append(args, prMetadataArgs(pr, "--add-reviewer", "--add-label", "--add-assignee")...)
+}
+
+// prMetadataArgs returns the gh arguments for reviewers, labels, assignees and milestone,
+// using the given flag names for the lists. gh takes teams as reviewers in org/team form.
+func prMetadataArgs(pr PullRequest, reviewerFlag, labelFlag, assigneeFlag string) []string {
+	var args []string
+	if reviewers := append(append([]string{}, pr.Reviewers...), pr.TeamReviewers...); len(reviewers) > 0 {
+		args = append(args, reviewerFlag, strings.Join(reviewers, ","))
+	}
+	if len(pr.Labels) > 0 {
+		args = append(args, labelFlag, strings.Join(pr.Labels, ","))
+	}
+	if len(pr.Assignees) > 0 {
+		args = append(args, assigneeFlag, strings.Join(pr.Assignees, ","))
+	}
+	if pr.Milestone != "" {
+		args = append(args, "--milestone", pr.Milestone)
+	}
+	return args
+}
+
 func (g *GitHubCLI) HasOpenPullRequest() (bool, error) {
 	output, err := runCommand("gh", "pr", "view", "--json", "state", "--jq", ".state")
 	if err != nil {
@@ -60,3 +99,80 @@ func (g *GitHubCLI) HasOpenPullRequest() (bool, error) {
 	}
 	return strings.TrimSpace(output) == "OPEN", nil
 }
+
+func (g *GitHubCLI) GetPullRequestDiff() (string, string, error) {
+	commit, err := g.openPullRequestField("headRefOid")
+	if err != nil {
+		return "", "", err
+	}
+	diff, err := runCommand("gh", "pr", "diff", "--color", "never")
+	if err != nil {
+		return "", "", fmt.Errorf("error fetching pull request diff: %w", err)
+	}
+	return diff, commit, nil
+}
+
+func (g *GitHubCLI) CreateReview(review Review) error {
+	number, err := g.openPullRequestField("number")
+	if err != nil {
+		return err
+	}
+
+	// gh api reads the payload from a file, since comments do not fit in -f fields
+	payload, err := os.CreateTemp("", "aigit-review-*.json")
+	if err != nil {
+		return fmt.Errorf("error writing review: %w", err)
+	}
+	defer os.Remove(payload.Name())
+	err = json.NewEncoder(payload).Encode(review)
+	if closeErr := payload.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("error writing review: %w", err)
+	}
+
+	output, err := runCommand("gh", createReviewArgs(number, payload.Name())...)
+	if err != nil {
+		return fmt.Errorf("failed to create review: %w", err)
+	}
+	fmt.Print(output)
+	return nil
+}
+
+// createReviewArgs returns the gh arguments posting the review in a file to a pull request
+func createReviewArgs(number, path string) []string {
+	return []string{"api", "--method", "POST", "repos/{owner}/{repo}/pulls/" + number + "/reviews", "--input", path, "--jq", ".html_url"}
+}
+
+// openPullRequestField returns a field of the open pull request of the current branch,
+// or ErrNoPullRequest if there is none
+func (g *GitHubCLI) openPullRequestField(field string) (string, error) {
+	output, err := runCommand("gh", "pr", "view", "--json", "state,"+field, "--jq", `select(.state == "OPEN") | .`+field)
+	if err != nil && !strings.Contains(output, "no pull requests found") {
+		return "", err
+	}
+	if value := strings.TrimSpace(output); err == nil && value != "" {
+		return value, nil
+	}
+	return "", ErrNoPullRequest
+}
+
+func (g *GitHubCLI) GetCurrentUser() (string, error) {
+	output, err := runCommand("gh", "api", "user", "--jq", ".login")
+	if err != nil {
+		return "", fmt.Errorf("error looking up the authenticated user: %w", err)
+	}
+	return strings.TrimSpace(output), nil
+}
+
+func (g *GitHubCLI) GetPullRequestBase() (string, error) {
+	output, err := runCommand("gh", "pr", "view", "--json", "state,baseRefName", "--jq", `select(.state == "OPEN") | .baseRefName`)
+	if err != nil {
+		if strings.Contains(output, "no pull requests found") {
+			return "", nil
+		}
+		return "", err
+	}
+	return strings.TrimSpace(output), nil
+}
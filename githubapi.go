@@ -0,0 +1,295 @@
+package aigit
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+const (
+	DefaultGitHubAPIURL = "https://api.github.com"
+
+	// githubPageSize is the number of items requested per page of a list, the most GitHub allows
+	githubPageSize = 100
+)
+
+// GitHubAPI implements the Forge interface for GitHub using its REST API, without the GitHub CLI
+type GitHubAPI struct {
+	api     *restClient
+	baseURL string
+	repo    string
+	git     Git
+}
+
+// NewGitHubAPI creates a forge for the repository at the given owner/repo path. baseURL is the
+// API root, https://api.github.com or https://<host>/api/v3 for GitHub Enterprise Server.
+func NewGitHubAPI(baseURL, token, repo string, git Git) *GitHubAPI {
+	if baseURL == "" {
+		baseURL = DefaultGitHubAPIURL
+	}
+	baseURL = strings.TrimSuffix(baseURL, "/")
+	api := newRestClient(baseURL)
+	api.mediaType = "application/vnd.github+json"
+	api.header.Set("X-GitHub-Api-Version", "2022-11-28")
+	if token != "" {
+		api.header.Set("Authorization", "Bearer "+token)
+	}
+	return &GitHubAPI{
+		api:     api,
+		baseURL: baseURL,
+		repo:    repo,
+		git:     git,
+	}
+}
+
+type githubBranch struct {
+	Ref string `json:"ref"`
+	SHA string `json:"sha"`
+}
+
+type githubPullRequest struct {
+	Number  int          `json:"number"`
+	HTMLURL string       `json:"html_url"`
+	Base    githubBranch `json:"base"`
+	Head    githubBranch `json:"head"`
+}
+
+type githubPullRequestRequest struct {
+	Title string `json:"title"`
+	Body  string `json:"body"`
+	Head  string `json:"head,omitempty"`
+	Base  string `json:"base,omitempty"`
+	Draft bool   `json:"draft,omitempty"`
+}
+
+type githubReviewRequest struct {
+	Reviewers     []string `json:"reviewers,omitempty"`
+	TeamReviewers []string `json:"team_reviewers,omitempty"`
+}
+
+func (g *GitHubAPI) CreatePullRequest(pr PullRequest) error {
+	branch, err := g.currentBranch()
+	if err != nil {
+		return err
+	}
+
+	base := pr.Base
+	if base == "" {
+		var repo struct {
+			DefaultBranch string `json:"default_branch"`
+		}
+		if err := g.api.request(http.MethodGet, g.repoPath(), nil, &repo); err != nil {
+			return fmt.Errorf("error reading the default branch: %w", err)
+		}
+		base = repo.DefaultBranch
+	}
+
+	req := githubPullRequestRequest{
+		Title: pr.Title,
+		Body:  pr.Description,
+		Head:  branch,
+		Base:  base,
+		Draft: pr.Draft,
+	}
+	var created githubPullRequest
+	if err := g.api.request(http.MethodPost, g.repoPath()+"/pulls", req, &created); err != nil {
+		return fmt.Errorf("failed to create pull request: %w", err)
+	}
+	if err := g.addMetadata(created.Number, pr); err != nil {
+		return err
+	}
+	fmt.Println(created.HTMLURL)
+	return nil
+}
+
+func (g *GitHubAPI) EditPullRequest(pr PullRequest) error {
+	existing, err := g.openPullRequest()
+	if err != nil {
+		return err
+	}
+	if existing == nil {
+		return fmt.Errorf("no open pull request to edit")
+	}
+
+	req := githubPullRequestRequest{
+		Title: pr.Title,
+		Body:  pr.Description,
+		Base:  pr.Base,
+	}
+	var updated githubPullRequest
+	if err := g.api.request(http.MethodPatch, g.repoPath()+"/pulls/"+strconv.Itoa(existing.Number), req, &updated); err != nil {
+		return fmt.Errorf("failed to edit pull request: %w", err)
+	}
+	if err := g.addMetadata(existing.Number, pr); err != nil {
+		return err
+	}
+	fmt.Println(updated.HTMLURL)
+	return nil
+}
+
+// addMetadata requests reviews and adds labels, assignees and the milestone to a pull request.
+// Like gh pr edit --add-*, existing reviewers, labels and assignees are kept.
+func (g *GitHubAPI) addMetadata(number int, pr PullRequest) error {
+	issuePath := g.repoPath() + "/issues/" + strconv.Itoa(number)
+	if len(pr.Reviewers) > 0 || len(pr.TeamReviewers) > 0 {
+		// Teams are requested by slug, within the organization owning the repository
+		req := githubReviewRequest{Reviewers: pr.Reviewers}
+		for _, team := range pr.TeamReviewers {
+			req.TeamReviewers = append(req.TeamReviewers, team[strings.LastIndex(team, "/")+1:])
+		}
+		path := g.repoPath() + "/pulls/" + strconv.Itoa(number) + "/requested_reviewers"
+		if err := g.api.request(http.MethodPost, path, req, nil); err != nil {
+			return fmt.Errorf("failed to request reviews: %w", err)
+		}
+	}
+	if len(pr.Labels) > 0 {
+		req := map[string][]string{"labels": pr.Labels}
+		if err := g.api.request(http.MethodPost, issuePath+"/labels", req, nil); err != nil {
+			return fmt.Errorf("failed to add labels: %w", err)
+		}
+	}
+	if len(pr.Assignees) > 0 {
+		req := map[string][]string{"assignees": pr.Assignees}
+		if err := g.api.request(http.MethodPost, issuePath+"/assignees", req, nil); err != nil {
+			return fmt.Errorf("failed to add assignees: %w", err)
+		}
+	}
+	if pr.Milestone != "" {
+		milestone, err := g.milestoneNumber(pr.Milestone)
+		if err != nil {
+			return err
+		}
+		req := map[string]int{"milestone": milestone}
+		if err := g.api.request(http.MethodPatch, issuePath, req, nil); err != nil {
+			return fmt.Errorf("failed to set milestone: %w", err)
+		}
+	}
+	return nil
+}
+
+// milestoneNumber looks up an open milestone by title
+func (g *GitHubAPI) milestoneNumber(title string) (int, error) {
+	for page := 1; ; page++ {
+		var milestones []struct {
+			Number int    `json:"number"`
+			Title  string `json:"title"`
+		}
+		if err := g.api.request(http.MethodGet, g.repoPath()+"/milestones"+githubPage(page), nil, &milestones); err != nil {
+			return 0, fmt.Errorf("error looking up milestone %s: %w", title, err)
+		}
+		for _, milestone := range milestones {
+			if milestone.Title == title {
+				return milestone.Number, nil
+			}
+		}
+		if len(milestones) < githubPageSize {
+			return 0, fmt.Errorf("milestone %s not found", title)
+		}
+	}
+}
+
+func (g *GitHubAPI) HasOpenPullRequest() (bool, error) {
+	pr, err := g.openPullRequest()
+	return pr != nil, err
+}
+
+func (g *GitHubAPI) GetPullRequestBase() (string, error) {
+	pr, err := g.openPullRequest()
+	if err != nil || pr == nil {
+		return "", err
+	}
+	return pr.Base.Ref, nil
+}
+
+func (g *GitHubAPI) GetCurrentUser() (string, error) {
+	var user struct {
+		Login string `json:"login"`
+	}
+	if err := g.api.request(http.MethodGet, "/user", nil, &user); err != nil {
+		return "", fmt.Errorf("error looking up the authenticated user: %w", err)
+	}
+	return user.Login, nil
+}
+
+func (g *GitHubAPI) DescribePullRequest(pr PullRequest, editing bool) string {
+	action := "create a pull request in"
+	if editing {
+		action = "edit the open pull request in"
+	}
+	return describePullRequest(fmt.Sprintf("github: %s %s on %s", action, g.repo, g.baseURL), pr, editing)
+}
+
+func (g *GitHubAPI) GetPullRequestDiff() (string, string, error) {
+	pr, err := g.requireOpenPullRequest()
+	if err != nil {
+		return "", "", err
+	}
+	diff, err := g.api.text(g.repoPath()+"/pulls/"+strconv.Itoa(pr.Number), "application/vnd.github.diff")
+	if err != nil {
+		return "", "", fmt.Errorf("error fetching pull request diff: %w", err)
+	}
+	return diff, pr.Head.SHA, nil
+}
+
+func (g *GitHubAPI) CreateReview(review Review) error {
+	pr, err := g.requireOpenPullRequest()
+	if err != nil {
+		return err
+	}
+	var created struct {
+		HTMLURL string `json:"html_url"`
+	}
+	if err := g.api.request(http.MethodPost, g.repoPath()+"/pulls/"+strconv.Itoa(pr.Number)+"/reviews", review, &created); err != nil {
+		return fmt.Errorf("failed to create review: %w", err)
+	}
+	fmt.Println(created.HTMLURL)
+	return nil
+}
+
+// requireOpenPullRequest returns the open pull request of the current branch, or ErrNoPullRequest
+func (g *GitHubAPI) requireOpenPullRequest() (*githubPullRequest, error) {
+	pr, err := g.openPullRequest()
+	if err == nil && pr == nil {
+		err = ErrNoPullRequest
+	}
+	return pr, err
+}
+
+// openPullRequest returns the open pull request of the current branch, or nil if there is none
+func (g *GitHubAPI) openPullRequest() (*githubPullRequest, error) {
+	branch, err := g.currentBranch()
+	if err != nil {
+		return nil, err
+	}
+	// The head is qualified by the owner of the branch, which is the owner of the repository
+	owner, _, _ := strings.Cut(g.repo, "/")
+	var prs []githubPullRequest
+	path := g.repoPath() + "/pulls?state=open&head=" + url.QueryEscape(owner+":"+branch)
+	if err := g.api.request(http.MethodGet, path, nil, &prs); err != nil {
+		return nil, fmt.Errorf("error looking up pull requests: %w", err)
+	}
+	if len(prs) == 0 {
+		return nil, nil
+	}
+	return &prs[0], nil
+}
+
+func (g *GitHubAPI) currentBranch() (string, error) {
+	branch, err := g.git.GetCurrentBranch()
+	if err != nil {
+		return "", fmt.Errorf("could not get current branch: %w", err)
+	}
+	return strings.TrimSpace(branch), nil
+}
+
+// repoPath returns the API path of the repository
+func (g *GitHubAPI) repoPath() string {
+	return "/repos/" + g.repo
+}
+
+// githubPage returns the query selecting a page of a list
+func githubPage(page int) string {
+	return fmt.Sprintf("?page=%d&per_page=%d", page, githubPageSize)
+}
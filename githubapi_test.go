@@ -0,0 +1,188 @@
+package aigit
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("GitHubAPI", func() {
+	type call struct {
+		Method string
+		Path   string
+		Body   map[string]any
+	}
+
+	var (
+		server *httptest.Server
+		calls  []call
+		open   []githubPullRequest
+		github *GitHubAPI
+	)
+
+	BeforeEach(func() {
+		calls = nil
+		open = nil
+		mux := http.NewServeMux()
+		reply := func(w http.ResponseWriter, status int, v any) {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(status)
+			Expect(json.NewEncoder(w).Encode(v)).To(Succeed())
+		}
+		record := func(status int, response any) http.HandlerFunc {
+			return func(w http.ResponseWriter, r *http.Request) {
+				var body map[string]any
+				Expect(json.NewDecoder(r.Body).Decode(&body)).To(Succeed())
+				calls = append(calls, call{Method: r.Method, Path: r.URL.Path, Body: body})
+				reply(w, status, response)
+			}
+		}
+		created := githubPullRequest{Number: 42, HTMLURL: "https://github.com/acme/api/pull/42"}
+
+		mux.HandleFunc("GET /repos/acme/api", func(w http.ResponseWriter, r *http.Request) {
+			reply(w, http.StatusOK, map[string]string{"default_branch": "trunk"})
+		})
+		mux.HandleFunc("GET /repos/acme/api/pulls", func(w http.ResponseWriter, r *http.Request) {
+			Expect(r.URL.Query().Get("state")).To(Equal("open"))
+			Expect(r.URL.Query().Get("head")).To(Equal("acme:feature"))
+			reply(w, http.StatusOK, open)
+		})
+		mux.HandleFunc("POST /repos/acme/api/pulls", record(http.StatusCreated, created))
+		mux.HandleFunc("PATCH /repos/acme/api/pulls/42", record(http.StatusOK, created))
+		mux.HandleFunc("POST /repos/acme/api/pulls/42/requested_reviewers", record(http.StatusCreated, created))
+		mux.HandleFunc("POST /repos/acme/api/issues/42/labels", record(http.StatusOK, []any{}))
+		mux.HandleFunc("POST /repos/acme/api/issues/42/assignees", record(http.StatusCreated, map[string]any{}))
+		mux.HandleFunc("PATCH /repos/acme/api/issues/42", record(http.StatusOK, map[string]any{}))
+		mux.HandleFunc("GET /repos/acme/api/pulls/42", func(w http.ResponseWriter, r *http.Request) {
+			Expect(r.Header.Get("Accept")).To(Equal("application/vnd.github.diff"))
+			w.Write([]byte("diff --git a/a.go b/a.go\n"))
+		})
+		mux.HandleFunc("POST /repos/acme/api/pulls/42/reviews", record(http.StatusOK, map[string]any{}))
+		mux.HandleFunc("GET /repos/acme/api/milestones", func(w http.ResponseWriter, r *http.Request) {
+			reply(w, http.StatusOK, []map[string]any{{"number": 3, "title": "v1.0"}})
+		})
+		mux.HandleFunc("GET /user", func(w http.ResponseWriter, r *http.Request) {
+			reply(w, http.StatusOK, map[string]string{"login": "carol"})
+		})
+		mux.HandleFunc("GET /repos/acme/missing/pulls", func(w http.ResponseWriter, r *http.Request) {
+			reply(w, http.StatusNotFound, map[string]string{"message": "Not Found"})
+		})
+		mux.HandleFunc("GET /repos/acme/limited/pulls", func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("X-RateLimit-Remaining", "0")
+			w.Header().Set("X-RateLimit-Reset", "1700000000")
+			reply(w, http.StatusForbidden, map[string]string{"message": "API rate limit exceeded for user ID 1."})
+		})
+
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path != "/repos/acme/api/pulls/42" {
+				Expect(r.Header.Get("Accept")).To(Equal("application/vnd.github+json"))
+			}
+			if r.Header.Get("Authorization") != "Bearer secret" {
+				reply(w, http.StatusUnauthorized, map[string]string{"message": "Bad credentials"})
+				return
+			}
+			mux.ServeHTTP(w, r)
+		}))
+		DeferCleanup(server.Close)
+
+		git := &mockGit{getCurrentBranchFunc: func() (string, error) { return "feature\n", nil }}
+		github = NewGitHubAPI(server.URL+"/", "secret", "acme/api", git)
+	})
+
+	It("should create a draft pull request against the default branch with its metadata", func() {
+		err := github.CreatePullRequest(PullRequest{
+			Title:         "Add thing",
+			Description:   "Adds a thing.",
+			Draft:         true,
+			Reviewers:     []string{"alice"},
+			TeamReviewers: []string{"acme/core"},
+			Labels:        []string{"bug"},
+			Assignees:     []string{"bob"},
+			Milestone:     "v1.0",
+		})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(calls).To(Equal([]call{
+			{"POST", "/repos/acme/api/pulls", map[string]any{"title": "Add thing", "body": "Adds a thing.", "head": "feature", "base": "trunk", "draft": true}},
+			{"POST", "/repos/acme/api/pulls/42/requested_reviewers", map[string]any{"reviewers": []any{"alice"}, "team_reviewers": []any{"core"}}},
+			{"POST", "/repos/acme/api/issues/42/labels", map[string]any{"labels": []any{"bug"}}},
+			{"POST", "/repos/acme/api/issues/42/assignees", map[string]any{"assignees": []any{"bob"}}},
+			{"PATCH", "/repos/acme/api/issues/42", map[string]any{"milestone": 3.0}},
+		}))
+	})
+
+	Context("when the branch has an open pull request", func() {
+		BeforeEach(func() {
+			open = []githubPullRequest{{Number: 42, Base: githubBranch{Ref: "release"}, Head: githubBranch{Ref: "feature", SHA: "abc123"}}}
+		})
+
+		It("should fetch its diff at the head commit", func() {
+			diff, commit, err := github.GetPullRequestDiff()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(diff).To(Equal("diff --git a/a.go b/a.go\n"))
+			Expect(commit).To(Equal("abc123"))
+		})
+
+		It("should create a pending review", func() {
+			err := github.CreateReview(Review{CommitID: "abc123", Comments: []ReviewComment{{Path: "a.go", Position: 2, Body: "**error**: Wrong"}}})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(calls).To(Equal([]call{{"POST", "/repos/acme/api/pulls/42/reviews", map[string]any{
+				"commit_id": "abc123",
+				"comments":  []any{map[string]any{"path": "a.go", "position": 2.0, "body": "**error**: Wrong"}},
+			}}}))
+		})
+
+		It("should report its base", func() {
+			Expect(github.HasOpenPullRequest()).To(BeTrue())
+			Expect(github.GetPullRequestBase()).To(Equal("release"))
+		})
+
+		It("should edit it", func() {
+			Expect(github.EditPullRequest(PullRequest{Title: "New title", Description: "New.", Base: "main"})).To(Succeed())
+			Expect(calls).To(Equal([]call{
+				{"PATCH", "/repos/acme/api/pulls/42", map[string]any{"title": "New title", "body": "New.", "base": "main"}},
+			}))
+		})
+	})
+
+	It("should report the authenticated user", func() {
+		Expect(github.GetCurrentUser()).To(Equal("carol"))
+	})
+
+	It("should report no open pull request", func() {
+		_, _, err := github.GetPullRequestDiff()
+		Expect(err).To(MatchError(ErrNoPullRequest))
+		Expect(github.HasOpenPullRequest()).To(BeFalse())
+		Expect(github.GetPullRequestBase()).To(BeEmpty())
+	})
+
+	Describe("errors", func() {
+		It("should report bad credentials", func() {
+			github = NewGitHubAPI(server.URL, "wrong", "acme/api", github.git)
+			_, err := github.HasOpenPullRequest()
+			Expect(err).To(MatchError(ErrForgeAuth))
+			Expect(err).To(MatchError(ContainSubstring("Bad credentials (status 401)")))
+		})
+
+		It("should report missing repositories", func() {
+			github = NewGitHubAPI(server.URL, "secret", "acme/missing", github.git)
+			_, err := github.HasOpenPullRequest()
+			Expect(err).To(MatchError(ErrForgeNotFound))
+		})
+
+		It("should report rate limits with their reset time", func() {
+			github = NewGitHubAPI(server.URL, "secret", "acme/limited", github.git)
+			_, err := github.HasOpenPullRequest()
+			Expect(err).To(MatchError(ErrForgeRateLimit))
+			Expect(err).NotTo(MatchError(ErrForgeAuth))
+
+			var apiErr *APIError
+			Expect(errors.As(err, &apiErr)).To(BeTrue())
+			Expect(apiErr.Reset).To(Equal(time.Unix(1700000000, 0)))
+		})
+	})
+})
@@ -0,0 +1,272 @@
+package aigit
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// GitLab implements the Forge interface with merge requests, using the GitLab REST API
+type GitLab struct {
+	api     *restClient
+	baseURL string
+	project string
+	git     Git
+}
+
+// NewGitLab creates a forge for the project at the given path, such as group/project, on the
+// GitLab instance at baseURL. An empty token omits authentication, which only suffices for
+// reading public projects.
+func NewGitLab(baseURL, token, project string, git Git) *GitLab {
+	baseURL = strings.TrimSuffix(baseURL, "/")
+	api := newRestClient(baseURL + "/api/v4")
+	if token != "" {
+		api.header.Set("PRIVATE-TOKEN", token)
+	}
+	return &GitLab{
+		api:     api,
+		baseURL: baseURL,
+		project: project,
+		git:     git,
+	}
+}
+
+type gitLabUser struct {
+	ID       int    `json:"id"`
+	Username string `json:"username"`
+}
+
+type gitLabMergeRequest struct {
+	IID             int          `json:"iid"`
+	SourceProjectID int          `json:"source_project_id"`
+	TargetProjectID int          `json:"target_project_id"`
+	Title           string       `json:"title"`
+	Draft           bool         `json:"draft"`
+	TargetBranch    string       `json:"target_branch"`
+	Reviewers       []gitLabUser `json:"reviewers"`
+	Assignees       []gitLabUser `json:"assignees"`
+}
+
+type gitLabMergeRequestRequest struct {
+	SourceBranch string `json:"source_branch,omitempty"`
+	TargetBranch string `json:"target_branch,omitempty"`
+	Title        string `json:"title"`
+	Description  string `json:"description"`
+	Labels       string `json:"labels,omitempty"`
+	AddLabels    string `json:"add_labels,omitempty"`
+	ReviewerIDs  []int  `json:"reviewer_ids,omitempty"`
+	AssigneeIDs  []int  `json:"assignee_ids,omitempty"`
+	MilestoneID  int    `json:"milestone_id,omitempty"`
+}
+
+func (g *GitLab) CreatePullRequest(pr PullRequest) error {
+	branch, err := g.currentBranch()
+	if err != nil {
+		return err
+	}
+
+	target := pr.Base
+	if target == "" {
+		var project struct {
+			DefaultBranch string `json:"default_branch"`
+		}
+		if err := g.api.request(http.MethodGet, g.projectPath(), nil, &project); err != nil {
+			return fmt.Errorf("error reading the default branch: %w", err)
+		}
+		target = project.DefaultBranch
+	}
+
+	title := pr.Title
+	if pr.Draft {
+		title = "Draft: " + title
+	}
+	req := gitLabMergeRequestRequest{
+		SourceBranch: branch,
+		TargetBranch: target,
+		Title:        title,
+		Description:  pr.Description,
+		Labels:       strings.Join(pr.Labels, ","),
+	}
+	if err := g.resolveMetadata(pr, &req, nil); err != nil {
+		return err
+	}
+
+	var created struct {
+		WebURL string `json:"web_url"`
+	}
+	if err := g.api.request(http.MethodPost, g.projectPath()+"/merge_requests", req, &created); err != nil {
+		return fmt.Errorf("failed to create merge request: %w", err)
+	}
+	fmt.Println(created.WebURL)
+	return nil
+}
+
+func (g *GitLab) EditPullRequest(pr PullRequest) error {
+	mr, err := g.openMergeRequest()
+	if err != nil {
+		return err
+	}
+	if mr == nil {
+		return fmt.Errorf("no open merge request to edit")
+	}
+
+	// Keep the draft status, which GitLab derives from the title
+	title := pr.Title
+	if mr.Draft {
+		title = "Draft: " + title
+	}
+	req := gitLabMergeRequestRequest{
+		TargetBranch: pr.Base,
+		Title:        title,
+		Description:  pr.Description,
+		AddLabels:    strings.Join(pr.Labels, ","),
+	}
+	if err := g.resolveMetadata(pr, &req, mr); err != nil {
+		return err
+	}
+
+	var updated struct {
+		WebURL string `json:"web_url"`
+	}
+	if err := g.api.request(http.MethodPut, g.projectPath()+"/merge_requests/"+strconv.Itoa(mr.IID), req, &updated); err != nil {
+		return fmt.Errorf("failed to edit merge request: %w", err)
+	}
+	fmt.Println(updated.WebURL)
+	return nil
+}
+
+// resolveMetadata looks up the ids of reviewers, assignees and the milestone. GitLab replaces
+// reviewers and assignees on update, so those of an existing merge request are kept.
+// Team reviewers have no GitLab equivalent and are ignored.
+func (g *GitLab) resolveMetadata(pr PullRequest, req *gitLabMergeRequestRequest, existing *gitLabMergeRequest) error {
+	var err error
+	if len(pr.Reviewers) > 0 {
+		if req.ReviewerIDs, err = g.userIDs(pr.Reviewers); err != nil {
+			return err
+		}
+		if existing != nil {
+			req.ReviewerIDs = mergeUserIDs(existing.Reviewers, req.ReviewerIDs)
+		}
+	}
+	if len(pr.Assignees) > 0 {
+		if req.AssigneeIDs, err = g.userIDs(pr.Assignees); err != nil {
+			return err
+		}
+		if existing != nil {
+			req.AssigneeIDs = mergeUserIDs(existing.Assignees, req.AssigneeIDs)
+		}
+	}
+	if pr.Milestone != "" {
+		var milestones []struct {
+			ID int `json:"id"`
+		}
+		path := g.projectPath() + "/milestones?title=" + url.QueryEscape(pr.Milestone)
+		if err := g.api.request(http.MethodGet, path, nil, &milestones); err != nil {
+			return fmt.Errorf("error looking up milestone %s: %w", pr.Milestone, err)
+		}
+		if len(milestones) == 0 {
+			return fmt.Errorf("milestone %s not found", pr.Milestone)
+		}
+		req.MilestoneID = milestones[0].ID
+	}
+	return nil
+}
+
+// userIDs looks up the ids of users by username
+func (g *GitLab) userIDs(usernames []string) ([]int, error) {
+	ids := make([]int, 0, len(usernames))
+	for _, username := range usernames {
+		var users []gitLabUser
+		if err := g.api.request(http.MethodGet, "/users?username="+url.QueryEscape(username), nil, &users); err != nil {
+			return nil, fmt.Errorf("error looking up user %s: %w", username, err)
+		}
+		if len(users) == 0 {
+			return nil, fmt.Errorf("user %s not found", username)
+		}
+		ids = append(ids, users[0].ID)
+	}
+	return ids, nil
+}
+
+// mergeUserIDs appends the ids of users to ids, skipping those already present
+func mergeUserIDs(users []gitLabUser, ids []int) []int {
+	merged := make([]int, 0, len(users)+len(ids))
+	seen := map[int]bool{}
+	for _, user := range users {
+		merged = append(merged, user.ID)
+		seen[user.ID] = true
+	}
+	for _, id := range ids {
+		if !seen[id] {
+			merged = append(merged, id)
+			seen[id] = true
+		}
+	}
+	return merged
+}
+
+func (g *GitLab) HasOpenPullRequest() (bool, error) {
+	mr, err := g.openMergeRequest()
+	return mr != nil, err
+}
+
+func (g *GitLab) GetPullRequestBase() (string, error) {
+	mr, err := g.openMergeRequest()
+	if err != nil || mr == nil {
+		return "", err
+	}
+	return mr.TargetBranch, nil
+}
+
+func (g *GitLab) GetCurrentUser() (string, error) {
+	var user gitLabUser
+	if err := g.api.request(http.MethodGet, "/user", nil, &user); err != nil {
+		return "", fmt.Errorf("error looking up the authenticated user: %w", err)
+	}
+	return user.Username, nil
+}
+
+func (g *GitLab) DescribePullRequest(pr PullRequest, editing bool) string {
+	action := "create a merge request in"
+	if editing {
+		action = "edit the open merge request in"
+	}
+	// Team reviewers are ignored, see resolveMetadata
+	pr.TeamReviewers = nil
+	return describePullRequest(fmt.Sprintf("gitlab: %s %s on %s", action, g.project, g.baseURL), pr, editing)
+}
+
+// openMergeRequest returns the open merge request of the current branch, or nil if there is none.
+// Merge requests from forks with a branch of the same name are skipped.
+func (g *GitLab) openMergeRequest() (*gitLabMergeRequest, error) {
+	branch, err := g.currentBranch()
+	if err != nil {
+		return nil, err
+	}
+	var mrs []gitLabMergeRequest
+	path := g.projectPath() + "/merge_requests?state=opened&source_branch=" + url.QueryEscape(branch)
+	if err := g.api.request(http.MethodGet, path, nil, &mrs); err != nil {
+		return nil, fmt.Errorf("error looking up merge requests: %w", err)
+	}
+	for i := range mrs {
+		if mrs[i].SourceProjectID == mrs[i].TargetProjectID {
+			return &mrs[i], nil
+		}
+	}
+	return nil, nil
+}
+
+func (g *GitLab) currentBranch() (string, error) {
+	branch, err := g.git.GetCurrentBranch()
+	if err != nil {
+		return "", fmt.Errorf("could not get current branch: %w", err)
+	}
+	return strings.TrimSpace(branch), nil
+}
+
+// projectPath returns the API path of the project, which is addressed by its URL-encoded path
+func (g *GitLab) projectPath() string {
+	return "/projects/" + url.PathEscape(g.project)
+}
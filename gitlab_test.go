@@ -0,0 +1,154 @@
+package aigit
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("GitLab", func() {
+	var (
+		server   *httptest.Server
+		requests []gitLabMergeRequestRequest
+		open     []gitLabMergeRequest
+		gitlab   *GitLab
+	)
+
+	BeforeEach(func() {
+		requests = nil
+		open = nil
+		mux := http.NewServeMux()
+		reply := func(w http.ResponseWriter, v any) {
+			w.Header().Set("Content-Type", "application/json")
+			Expect(json.NewEncoder(w).Encode(v)).To(Succeed())
+		}
+		record := func(w http.ResponseWriter, r *http.Request) {
+			var req gitLabMergeRequestRequest
+			Expect(json.NewDecoder(r.Body).Decode(&req)).To(Succeed())
+			requests = append(requests, req)
+			reply(w, map[string]string{"web_url": "https://gitlab.test/group/api/-/merge_requests/3"})
+		}
+		mux.HandleFunc("GET /api/v4/projects/group%2Fapi", func(w http.ResponseWriter, r *http.Request) {
+			reply(w, map[string]string{"default_branch": "trunk"})
+		})
+		mux.HandleFunc("GET /api/v4/projects/group%2Fapi/merge_requests", func(w http.ResponseWriter, r *http.Request) {
+			Expect(r.URL.Query().Get("state")).To(Equal("opened"))
+			Expect(r.URL.Query().Get("source_branch")).To(Equal("feature"))
+			reply(w, open)
+		})
+		mux.HandleFunc("POST /api/v4/projects/group%2Fapi/merge_requests", record)
+		mux.HandleFunc("PUT /api/v4/projects/group%2Fapi/merge_requests/3", record)
+		mux.HandleFunc("GET /api/v4/projects/group%2Fapi/milestones", func(w http.ResponseWriter, r *http.Request) {
+			Expect(r.URL.Query().Get("title")).To(Equal("v1.0"))
+			reply(w, []map[string]int{{"id": 7}})
+		})
+		mux.HandleFunc("GET /api/v4/user", func(w http.ResponseWriter, r *http.Request) {
+			reply(w, gitLabUser{ID: 3, Username: "carol"})
+		})
+		mux.HandleFunc("GET /api/v4/users", func(w http.ResponseWriter, r *http.Request) {
+			ids := map[string]int{"alice": 1, "bob": 2}
+			if id, ok := ids[r.URL.Query().Get("username")]; ok {
+				reply(w, []gitLabUser{{ID: id, Username: r.URL.Query().Get("username")}})
+				return
+			}
+			reply(w, []gitLabUser{})
+		})
+
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("PRIVATE-TOKEN") != "secret" {
+				w.WriteHeader(http.StatusUnauthorized)
+				w.Write([]byte(`{"message":"401 Unauthorized"}`))
+				return
+			}
+			mux.ServeHTTP(w, r)
+		}))
+		DeferCleanup(server.Close)
+
+		git := &mockGit{getCurrentBranchFunc: func() (string, error) { return "feature\n", nil }}
+		gitlab = NewGitLab(server.URL+"/", "secret", "group/api", git)
+	})
+
+	It("should create a draft merge request against the default branch", func() {
+		err := gitlab.CreatePullRequest(PullRequest{
+			Title:         "Add thing",
+			Description:   "Adds a thing.",
+			Draft:         true,
+			Reviewers:     []string{"alice"},
+			TeamReviewers: []string{"acme/api"},
+			Labels:        []string{"bug", "api"},
+			Assignees:     []string{"bob"},
+			Milestone:     "v1.0",
+		})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(requests).To(Equal([]gitLabMergeRequestRequest{{
+			SourceBranch: "feature",
+			TargetBranch: "trunk",
+			Title:        "Draft: Add thing",
+			Description:  "Adds a thing.",
+			Labels:       "bug,api",
+			ReviewerIDs:  []int{1},
+			AssigneeIDs:  []int{2},
+			MilestoneID:  7,
+		}}))
+	})
+
+	It("should report unknown users", func() {
+		err := gitlab.CreatePullRequest(PullRequest{Title: "Add thing", Base: "main", Reviewers: []string{"mallory"}})
+		Expect(err).To(MatchError(ContainSubstring("user mallory not found")))
+		Expect(requests).To(BeEmpty())
+	})
+
+	Context("when the branch has an open merge request", func() {
+		BeforeEach(func() {
+			open = []gitLabMergeRequest{{
+				// A fork with a branch of the same name
+				IID:             8,
+				SourceProjectID: 9,
+				TargetProjectID: 1,
+				TargetBranch:    "main",
+			}, {
+				IID:             3,
+				SourceProjectID: 1,
+				TargetProjectID: 1,
+				Title:           "Draft: Old title",
+				Draft:           true,
+				TargetBranch:    "release",
+				Reviewers:       []gitLabUser{{ID: 2, Username: "bob"}},
+			}}
+		})
+
+		It("should report its base", func() {
+			Expect(gitlab.HasOpenPullRequest()).To(BeTrue())
+			Expect(gitlab.GetPullRequestBase()).To(Equal("release"))
+		})
+
+		It("should keep its draft status and existing reviewers", func() {
+			err := gitlab.EditPullRequest(PullRequest{Title: "New title", Description: "New.", Reviewers: []string{"alice", "bob"}, Labels: []string{"api"}})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(requests).To(Equal([]gitLabMergeRequestRequest{{
+				Title:       "Draft: New title",
+				Description: "New.",
+				AddLabels:   "api",
+				ReviewerIDs: []int{2, 1},
+			}}))
+		})
+	})
+
+	It("should report the authenticated user", func() {
+		Expect(gitlab.GetCurrentUser()).To(Equal("carol"))
+	})
+
+	It("should report no open merge request", func() {
+		Expect(gitlab.HasOpenPullRequest()).To(BeFalse())
+		Expect(gitlab.GetPullRequestBase()).To(BeEmpty())
+	})
+
+	It("should return API errors", func() {
+		gitlab = NewGitLab(server.URL, "wrong", "group/api", gitlab.git)
+		_, err := gitlab.HasOpenPullRequest()
+		Expect(err).To(MatchError(ContainSubstring("401 Unauthorized (status 401)")))
+	})
+})
@@ -0,0 +1,592 @@
+package aigit
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	fdiff "github.com/go-git/go-git/v5/plumbing/format/diff"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/storer"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	githttp "github.com/go-git/go-git/v5/plumbing/transport/http"
+	"github.com/go-git/go-git/v5/utils/diff"
+	"github.com/sergi/go-diff/diffmatchpatch"
+)
+
+// gitTokenEnv holds a token for pushing to https remotes with the go-git backend, which does not
+// use git credential helpers. GITHUB_TOKEN is used as well, but only for github.com.
+const gitTokenEnv = "AIGIT_GIT_TOKEN"
+
+// GoGit implements the Git interface natively with go-git, without requiring a git binary.
+// Commit hooks and signing are not supported.
+type GoGit struct {
+	repo *git.Repository
+}
+
+// NewGoGit opens the repository containing the working directory
+func NewGoGit() (Git, error) {
+	repo, err := git.PlainOpenWithOptions(".", &git.PlainOpenOptions{DetectDotGit: true})
+	if err != nil {
+		return nil, fmt.Errorf("error opening repository: %w", err)
+	}
+	return NewGoGitRepository(repo), nil
+}
+
+// NewGoGitRepository wraps an open repository, which may be held in memory
+func NewGoGitRepository(repo *git.Repository) *GoGit {
+	return &GoGit{repo: repo}
+}
+
+func (g *GoGit) GetStagedDiff() (string, error) {
+	changes, err := g.stagedChanges()
+	if err != nil {
+		return "", err
+	}
+
+	patch := make(goGitPatch, 0, len(changes))
+	for _, change := range changes {
+		filePatch, err := g.filePatch(change)
+		if err != nil {
+			return "", err
+		}
+		patch = append(patch, filePatch)
+	}
+
+	var out strings.Builder
+	if err := fdiff.NewUnifiedEncoder(&out, fdiff.DefaultContextLines).Encode(patch); err != nil {
+		return "", fmt.Errorf("error encoding diff: %w", err)
+	}
+	return out.String(), nil
+}
+
+func (g *GoGit) GetStagedFiles() ([]string, error) {
+	changes, err := g.stagedChanges()
+	if err != nil {
+		return nil, err
+	}
+	files := make([]string, len(changes))
+	for i, change := range changes {
+		files[i] = change.path()
+	}
+	return files, nil
+}
+
+func (g *GoGit) Commit(message string) error {
+	w, err := g.repo.Worktree()
+	if err != nil {
+		return fmt.Errorf("error opening worktree: %w", err)
+	}
+	if _, err := w.Commit(message, &git.CommitOptions{}); err != nil {
+		return fmt.Errorf("error committing: %w", err)
+	}
+	return nil
+}
+
+func (g *GoGit) Amend(message string) error {
+	head, err := g.headCommit()
+	if err != nil {
+		return fmt.Errorf("error amending commit: %w", err)
+	}
+	committer, err := g.signature()
+	if err != nil {
+		return fmt.Errorf("error amending commit: %w", err)
+	}
+	w, err := g.repo.Worktree()
+	if err != nil {
+		return fmt.Errorf("error opening worktree: %w", err)
+	}
+
+	// Like git commit --amend, keep the original author
+	author := head.Author
+	opts := &git.CommitOptions{Amend: true, Author: &author, Committer: committer}
+	if _, err := w.Commit(message, opts); err != nil {
+		return fmt.Errorf("error amending commit: %w", err)
+	}
+	return nil
+}
+
+func (g *GoGit) GetCurrentBranch() (string, error) {
+	// Read HEAD without resolving it, so a branch without commits still has a name
+	ref, err := g.repo.Storer.Reference(plumbing.HEAD)
+	if err != nil {
+		return "", fmt.Errorf("error reading HEAD: %w", err)
+	}
+	if ref.Type() != plumbing.SymbolicReference || !ref.Target().IsBranch() {
+		return "HEAD", nil
+	}
+	return ref.Target().Short(), nil
+}
+
+func (g *GoGit) GetBaseBranch() (string, error) {
+	branch, err := g.GetCurrentBranch()
+	if err != nil {
+		return "", fmt.Errorf("could not get current branch: %w", err)
+	}
+
+	// A branch tracking another branch, e.g. one created from origin/develop, targets it
+	cfg, err := g.repo.Config()
+	if err != nil {
+		return "", fmt.Errorf("error reading repository config: %w", err)
+	}
+	if b, ok := cfg.Branches[branch]; ok && b.Merge.IsBranch() && b.Merge.Short() != branch {
+		return b.Merge.Short(), nil
+	}
+
+	// Otherwise target the default branch of the remote
+	head, err := g.repo.Reference(plumbing.NewRemoteHEADReferenceName("origin"), false)
+	if err == nil && head.Type() == plumbing.SymbolicReference {
+		return strings.TrimPrefix(head.Target().String(), "refs/remotes/origin/"), nil
+	}
+
+	for _, base := range baseBranchCandidates {
+		for _, ref := range []plumbing.ReferenceName{plumbing.NewRemoteReferenceName("origin", base), plumbing.NewBranchReferenceName(base)} {
+			if _, err := g.repo.Reference(ref, false); err == nil {
+				return base, nil
+			}
+		}
+	}
+	return "", fmt.Errorf("could not detect the base branch, use --base to set it")
+}
+
+func (g *GoGit) GetCommitHistory(baseBranch string) (string, error) {
+	head, mergeBases, err := g.mergeBase(baseBranch)
+	if err != nil {
+		return "", err
+	}
+
+	// Commits reachable from the merge base are excluded, like git log $(git merge-base HEAD base)..HEAD
+	exclude := map[plumbing.Hash]bool{}
+	for _, mergeBase := range mergeBases {
+		err = object.NewCommitPreorderIter(mergeBase, exclude, nil).ForEach(func(c *object.Commit) error {
+			exclude[c.Hash] = true
+			return nil
+		})
+		if err != nil {
+			return "", fmt.Errorf("error reading history of %s: %w", baseBranch, err)
+		}
+	}
+
+	var commits []string
+	err = object.NewCommitPreorderIter(head, exclude, nil).ForEach(func(c *object.Commit) error {
+		commits = append(commits, formatCommit(c))
+		return nil
+	})
+	if err != nil {
+		return "", fmt.Errorf("error reading commit history: %w", err)
+	}
+	return strings.Join(commits, "\n"), nil
+}
+
+func (g *GoGit) GetBranchDiff(baseBranch string) (string, error) {
+	head, mergeBases, err := g.mergeBase(baseBranch)
+	if err != nil {
+		return "", err
+	}
+	patch, err := mergeBases[0].Patch(head)
+	if err != nil {
+		return "", fmt.Errorf("error diffing against %s: %w", baseBranch, err)
+	}
+	return patch.String(), nil
+}
+
+// mergeBase returns the HEAD commit and the commits where it forked from the base branch
+func (g *GoGit) mergeBase(baseBranch string) (*object.Commit, []*object.Commit, error) {
+	// Prefer the remote branch, since the local one may be stale or missing
+	revision := plumbing.Revision(baseBranch)
+	if _, err := g.repo.Reference(plumbing.NewRemoteReferenceName("origin", baseBranch), false); err == nil {
+		revision = plumbing.Revision(plumbing.NewRemoteReferenceName("origin", baseBranch))
+	}
+	base, err := g.repo.ResolveRevision(revision)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error resolving %s: %w", baseBranch, err)
+	}
+	baseCommit, err := g.repo.CommitObject(*base)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error reading %s: %w", baseBranch, err)
+	}
+	head, err := g.headCommit()
+	if err != nil {
+		return nil, nil, err
+	}
+	mergeBases, err := head.MergeBase(baseCommit)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error finding merge base with %s: %w", baseBranch, err)
+	}
+	if len(mergeBases) == 0 {
+		return nil, nil, fmt.Errorf("no common history with %s", baseBranch)
+	}
+	return head, mergeBases, nil
+}
+
+func (g *GoGit) GetRecentCommits(n int) ([]string, error) {
+	head, err := g.headCommit()
+	if err != nil {
+		return nil, err
+	}
+
+	var subjects []string
+	err = object.NewCommitPreorderIter(head, nil, nil).ForEach(func(c *object.Commit) error {
+		if len(subjects) >= n {
+			return storer.ErrStop
+		}
+		subjects = append(subjects, commitSubject(c.Message))
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error reading recent commits: %w", err)
+	}
+	return subjects, nil
+}
+
+func (g *GoGit) Push() error {
+	return g.push(nil)
+}
+
+func (g *GoGit) ForcePushWithLease(expectedSHA string) error {
+	return g.push(&git.ForceWithLease{Hash: plumbing.NewHash(expectedSHA)})
+}
+
+// push pushes the current branch to origin and sets it as the upstream, like git push --set-upstream
+func (g *GoGit) push(lease *git.ForceWithLease) error {
+	branch, err := g.GetCurrentBranch()
+	if err != nil {
+		return fmt.Errorf("could not get current branch: %w", err)
+	}
+	ref := plumbing.NewBranchReferenceName(branch)
+	spec := config.RefSpec(ref + ":" + ref)
+	if lease != nil {
+		lease.RefName = ref
+		spec = "+" + spec
+	}
+
+	err = g.repo.Push(&git.PushOptions{
+		RemoteName:     "origin",
+		RefSpecs:       []config.RefSpec{spec},
+		Auth:           g.pushAuth(),
+		ForceWithLease: lease,
+	})
+	if err != nil && !errors.Is(err, git.NoErrAlreadyUpToDate) {
+		return goGitPushError(err)
+	}
+	return g.setUpstream(branch)
+}
+
+// pushAuth returns token credentials for https remotes. SSH remotes use the SSH agent.
+func (g *GoGit) pushAuth() transport.AuthMethod {
+	remote, err := g.repo.Remote("origin")
+	if err != nil || len(remote.Config().URLs) == 0 {
+		return nil
+	}
+	if token := pushToken(remote.Config().URLs[0]); token != "" {
+		return &githttp.BasicAuth{Username: "x-access-token", Password: token}
+	}
+	return nil
+}
+
+// pushToken returns the token to push to a remote URL with. Tokens are never sent over plain
+// http, and GITHUB_TOKEN is only sent to github.com.
+func pushToken(remoteURL string) string {
+	u, err := url.Parse(remoteURL)
+	if err != nil || u.Scheme != "https" {
+		return ""
+	}
+	if token := os.Getenv(gitTokenEnv); token != "" {
+		return token
+	}
+	if strings.EqualFold(u.Hostname(), "github.com") {
+		return os.Getenv("GITHUB_TOKEN")
+	}
+	return ""
+}
+
+func (g *GoGit) setUpstream(branch string) error {
+	cfg, err := g.repo.Config()
+	if err != nil {
+		return fmt.Errorf("error reading repository config: %w", err)
+	}
+	if b, ok := cfg.Branches[branch]; ok && b.Remote != "" {
+		return nil
+	}
+	cfg.Branches[branch] = &config.Branch{
+		Name:   branch,
+		Remote: "origin",
+		Merge:  plumbing.NewBranchReferenceName(branch),
+	}
+	if err := g.repo.SetConfig(cfg); err != nil {
+		return fmt.Errorf("error setting upstream of %s: %w", branch, err)
+	}
+	return nil
+}
+
+func (g *GoGit) GetRemoteBranchSHA() (string, error) {
+	branch, err := g.GetCurrentBranch()
+	if err != nil {
+		return "", fmt.Errorf("could not get current branch: %w", err)
+	}
+	ref, err := g.repo.Reference(plumbing.NewRemoteReferenceName("origin", branch), true)
+	if err != nil {
+		return "", fmt.Errorf("no remote tracking branch for %s, run git fetch first: %w", branch, err)
+	}
+	return ref.Hash().String(), nil
+}
+
+func (g *GoGit) IsAncestor(sha string) (bool, error) {
+	head, err := g.headCommit()
+	if err != nil {
+		return false, err
+	}
+	commit, err := g.repo.CommitObject(plumbing.NewHash(sha))
+	if err != nil {
+		return false, fmt.Errorf("error reading commit %s: %w", sha, err)
+	}
+	return commit.IsAncestor(head)
+}
+
+func (g *GoGit) GetRemoteURL(name string) (string, error) {
+	remote, err := g.repo.Remote(name)
+	if err != nil {
+		return "", fmt.Errorf("error reading URL of remote %s: %w", name, err)
+	}
+	if len(remote.Config().URLs) == 0 {
+		return "", fmt.Errorf("remote %s has no URL", name)
+	}
+	return remote.Config().URLs[0], nil
+}
+
+func (g *GoGit) headCommit() (*object.Commit, error) {
+	ref, err := g.repo.Head()
+	if err != nil {
+		return nil, fmt.Errorf("error reading HEAD: %w", err)
+	}
+	commit, err := g.repo.CommitObject(ref.Hash())
+	if err != nil {
+		return nil, fmt.Errorf("error reading HEAD commit: %w", err)
+	}
+	return commit, nil
+}
+
+// signature returns the configured user, for the committer of amended commits
+func (g *GoGit) signature() (*object.Signature, error) {
+	cfg, err := g.repo.ConfigScoped(config.SystemScope)
+	if err != nil {
+		return nil, fmt.Errorf("error reading git config: %w", err)
+	}
+	name, email := cfg.Committer.Name, cfg.Committer.Email
+	if name == "" || email == "" {
+		name, email = cfg.User.Name, cfg.User.Email
+	}
+	if name == "" || email == "" {
+		return nil, git.ErrMissingAuthor
+	}
+	return &object.Signature{Name: name, Email: email, When: time.Now()}, nil
+}
+
+// goGitFile is a file version in a staged change
+type goGitFile struct {
+	name string
+	hash plumbing.Hash
+	mode filemode.FileMode
+}
+
+func (f *goGitFile) Hash() plumbing.Hash     { return f.hash }
+func (f *goGitFile) Mode() filemode.FileMode { return f.mode }
+func (f *goGitFile) Path() string            { return f.name }
+
+// goGitChange is a file that differs between HEAD and the index.
+// From is nil for added files, and To is nil for deleted files.
+type goGitChange struct {
+	From, To *goGitFile
+}
+
+func (c goGitChange) path() string {
+	if c.To != nil {
+		return c.To.name
+	}
+	return c.From.name
+}
+
+// stagedChanges compares the index to the HEAD tree, like git diff --staged
+func (g *GoGit) stagedChanges() ([]goGitChange, error) {
+	head, err := g.headFiles()
+	if err != nil {
+		return nil, err
+	}
+	idx, err := g.repo.Storer.Index()
+	if err != nil {
+		return nil, fmt.Errorf("error reading index: %w", err)
+	}
+
+	var changes []goGitChange
+	staged := map[string]bool{}
+	for _, entry := range idx.Entries {
+		// Skip unmerged entries, which have a non-zero stage. index.Merged is
+		// misleadingly defined as 1, so compare to zero directly.
+		if entry.Stage != 0 || entry.Mode == filemode.Submodule {
+			continue
+		}
+		staged[entry.Name] = true
+		to := &goGitFile{name: entry.Name, hash: entry.Hash, mode: entry.Mode}
+		from := head[entry.Name]
+		if from != nil && from.hash == to.hash && from.mode == to.mode {
+			continue
+		}
+		changes = append(changes, goGitChange{From: from, To: to})
+	}
+	for name, from := range head {
+		if !staged[name] {
+			changes = append(changes, goGitChange{From: from})
+		}
+	}
+
+	sort.Slice(changes, func(i, j int) bool { return changes[i].path() < changes[j].path() })
+	return changes, nil
+}
+
+// headFiles returns the files in the HEAD commit by path, or none on a branch without commits
+func (g *GoGit) headFiles() (map[string]*goGitFile, error) {
+	files := map[string]*goGitFile{}
+	if _, err := g.repo.Head(); errors.Is(err, plumbing.ErrReferenceNotFound) {
+		return files, nil
+	}
+	head, err := g.headCommit()
+	if err != nil {
+		return nil, err
+	}
+	tree, err := head.Tree()
+	if err != nil {
+		return nil, fmt.Errorf("error reading HEAD tree: %w", err)
+	}
+	err = tree.Files().ForEach(func(f *object.File) error {
+		files[f.Name] = &goGitFile{name: f.Name, hash: f.Hash, mode: f.Mode}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error reading HEAD tree: %w", err)
+	}
+	return files, nil
+}
+
+func (g *GoGit) filePatch(change goGitChange) (*goGitFilePatch, error) {
+	patch := &goGitFilePatch{from: change.From, to: change.To}
+	from, err := g.blob(change.From)
+	if err != nil {
+		return nil, err
+	}
+	to, err := g.blob(change.To)
+	if err != nil {
+		return nil, err
+	}
+	if isBinary(from) || isBinary(to) {
+		patch.binary = true
+		return patch, nil
+	}
+
+	for _, d := range diff.Do(string(from), string(to)) {
+		op := fdiff.Equal
+		switch d.Type {
+		case diffmatchpatch.DiffInsert:
+			op = fdiff.Add
+		case diffmatchpatch.DiffDelete:
+			op = fdiff.Delete
+		}
+		patch.chunks = append(patch.chunks, goGitChunk{content: d.Text, op: op})
+	}
+	return patch, nil
+}
+
+func (g *GoGit) blob(file *goGitFile) ([]byte, error) {
+	if file == nil {
+		return nil, nil
+	}
+	blob, err := g.repo.BlobObject(file.hash)
+	if err != nil {
+		return nil, fmt.Errorf("error reading %s: %w", file.name, err)
+	}
+	r, err := blob.Reader()
+	if err != nil {
+		return nil, fmt.Errorf("error reading %s: %w", file.name, err)
+	}
+	defer r.Close()
+	return io.ReadAll(r)
+}
+
+// isBinary guesses whether content is binary the way git does, by looking for a NUL byte
+func isBinary(content []byte) bool {
+	return bytes.IndexByte(content[:min(len(content), 8000)], 0) >= 0
+}
+
+// goGitPushError wraps a failed go-git push in a PushError
+func goGitPushError(err error) error {
+	reason := classifyPushFailure(err.Error())
+	var netErr net.Error
+	switch {
+	case errors.Is(err, transport.ErrAuthenticationRequired), errors.Is(err, transport.ErrAuthorizationFailed):
+		reason = PushAuth
+	case errors.As(err, &netErr):
+		reason = PushNetwork
+	}
+	return &PushError{Reason: reason, Err: err}
+}
+
+// formatCommit renders a commit as its short hash and subject, with the body indented below
+func formatCommit(c *object.Commit) string {
+	subject, body, _ := strings.Cut(strings.TrimSpace(c.Message), "\n")
+	lines := []string{c.Hash.String()[:7] + " " + subject}
+	if body = strings.TrimSpace(body); body != "" {
+		for _, line := range strings.Split(body, "\n") {
+			lines = append(lines, strings.TrimRight("  "+line, " "))
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// commitSubject returns the first line of a commit message
+func commitSubject(message string) string {
+	subject, _, _ := strings.Cut(strings.TrimSpace(message), "\n")
+	return subject
+}
+
+type goGitPatch []fdiff.FilePatch
+
+func (p goGitPatch) FilePatches() []fdiff.FilePatch { return p }
+func (p goGitPatch) Message() string                { return "" }
+
+type goGitFilePatch struct {
+	from, to *goGitFile
+	binary   bool
+	chunks   []fdiff.Chunk
+}
+
+func (p *goGitFilePatch) IsBinary() bool        { return p.binary }
+func (p *goGitFilePatch) Chunks() []fdiff.Chunk { return p.chunks }
+
+// Files returns untyped nils for missing sides, which the encoder checks for
+func (p *goGitFilePatch) Files() (from, to fdiff.File) {
+	if p.from != nil {
+		from = p.from
+	}
+	if p.to != nil {
+		to = p.to
+	}
+	return from, to
+}
+
+type goGitChunk struct {
+	content string
+	op      fdiff.Operation
+}
+
+func (c goGitChunk) Content() string       { return c.content }
+func (c goGitChunk) Type() fdiff.Operation { return c.op }
@@ -0,0 +1,246 @@
+package aigit
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/go-git/go-billy/v5"
+	"github.com/go-git/go-billy/v5/memfs"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/transport/client"
+	"github.com/go-git/go-git/v5/plumbing/transport/file"
+	"github.com/go-git/go-git/v5/plumbing/transport/server"
+	"github.com/go-git/go-git/v5/storage/memory"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("GoGit", func() {
+	var (
+		fs   billy.Filesystem
+		repo *git.Repository
+		wt   *git.Worktree
+		g    *GoGit
+	)
+
+	write := func(path, content string) {
+		f, err := fs.Create(path)
+		Expect(err).NotTo(HaveOccurred())
+		_, err = io.WriteString(f, content)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(f.Close()).To(Succeed())
+		_, err = wt.Add(path)
+		Expect(err).NotTo(HaveOccurred())
+	}
+
+	BeforeEach(func() {
+		var err error
+		fs = memfs.New()
+		repo, err = git.Init(memory.NewStorage(), fs)
+		Expect(err).NotTo(HaveOccurred())
+		cfg, err := repo.Config()
+		Expect(err).NotTo(HaveOccurred())
+		cfg.User.Name = "Test"
+		cfg.User.Email = "test@example.com"
+		Expect(repo.SetConfig(cfg)).To(Succeed())
+		wt, err = repo.Worktree()
+		Expect(err).NotTo(HaveOccurred())
+		g = NewGoGitRepository(repo)
+	})
+
+	It("should name the branch before the first commit", func() {
+		Expect(g.GetCurrentBranch()).To(Equal("master"))
+	})
+
+	It("should fail to read a remote without a URL", func() {
+		dir := GinkgoT().TempDir()
+		_, err := git.PlainInit(dir, false)
+		Expect(err).NotTo(HaveOccurred())
+		// git accepts a remote with only a fetch refspec, go-git refuses to create one
+		remoteConfig := "[remote \"origin\"]\n\tfetch = +refs/heads/*:refs/remotes/origin/*\n"
+		Expect(os.WriteFile(filepath.Join(dir, ".git", "config"), []byte(remoteConfig), 0o644)).To(Succeed())
+		repo, err := git.PlainOpen(dir)
+		Expect(err).NotTo(HaveOccurred())
+		_, err = NewGoGitRepository(repo).GetRemoteURL("origin")
+		Expect(err).To(MatchError("remote origin has no URL"))
+	})
+
+	Context("with a commit", func() {
+		BeforeEach(func() {
+			write("a.txt", "one\ntwo\nthree\n")
+			write("b.txt", "gone\n")
+			Expect(g.Commit("feat: initial commit")).To(Succeed())
+		})
+
+		It("should have nothing staged after committing", func() {
+			Expect(g.GetStagedDiff()).To(BeEmpty())
+			Expect(g.GetStagedFiles()).To(BeEmpty())
+			Expect(g.GetRecentCommits(5)).To(Equal([]string{"feat: initial commit"}))
+			Expect(g.GetBaseBranch()).To(Equal("master"))
+		})
+
+		It("should diff the index against HEAD", func() {
+			write("a.txt", "one\n2\nthree\n")
+			write("c.txt", "new\n")
+			_, err := wt.Remove("b.txt")
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(g.GetStagedFiles()).To(Equal([]string{"a.txt", "b.txt", "c.txt"}))
+			diff, err := g.GetStagedDiff()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(diff).To(ContainSubstring("diff --git a/a.txt b/a.txt\n"))
+			Expect(diff).To(ContainSubstring("@@ -1,3 +1,3 @@\n one\n-two\n+2\n three\n"))
+			Expect(diff).To(ContainSubstring("deleted file mode 100644\n"))
+			Expect(diff).To(ContainSubstring("--- /dev/null\n+++ b/c.txt\n@@ -0,0 +1 @@\n+new\n"))
+
+			files := parseDiff(diff)
+			Expect(files).To(HaveLen(3))
+			Expect(files[2].Path).To(Equal("c.txt"))
+		})
+
+		It("should amend the last commit", func() {
+			write("c.txt", "new\n")
+			Expect(g.Amend("feat: amended")).To(Succeed())
+			Expect(g.GetRecentCommits(5)).To(Equal([]string{"feat: amended"}))
+			Expect(g.GetStagedFiles()).To(BeEmpty())
+		})
+
+		It("should list the commits on a branch", func() {
+			Expect(wt.Checkout(&git.CheckoutOptions{Branch: plumbing.NewBranchReferenceName("feature"), Create: true})).To(Succeed())
+			write("c.txt", "new\n")
+			Expect(g.Commit("feat: add c\n\nWith a body")).To(Succeed())
+
+			Expect(g.GetCurrentBranch()).To(Equal("feature"))
+			history, err := g.GetCommitHistory("master")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(history).To(MatchRegexp(`^[0-9a-f]{7} feat: add c\n  With a body$`))
+
+			diff, err := g.GetBranchDiff("master")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(diff).To(ContainSubstring("+++ b/c.txt\n@@ -0,0 +1 @@\n+new\n"))
+			Expect(diff).NotTo(ContainSubstring("a.txt"))
+		})
+
+		It("should list history from the remote base when the local one is stale", func() {
+			stale, err := repo.Head()
+			Expect(err).NotTo(HaveOccurred())
+			write("c.txt", "new\n")
+			Expect(g.Commit("feat: merged upstream")).To(Succeed())
+			upstream, err := repo.Head()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(repo.Storer.SetReference(plumbing.NewHashReference(plumbing.NewRemoteReferenceName("origin", "master"), upstream.Hash()))).To(Succeed())
+
+			Expect(wt.Checkout(&git.CheckoutOptions{Branch: plumbing.NewBranchReferenceName("feature"), Create: true})).To(Succeed())
+			write("d.txt", "new\n")
+			Expect(g.Commit("feat: add d")).To(Succeed())
+			Expect(repo.Storer.SetReference(plumbing.NewHashReference(plumbing.NewBranchReferenceName("master"), stale.Hash()))).To(Succeed())
+
+			history, err := g.GetCommitHistory("master")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(history).To(MatchRegexp(`^[0-9a-f]{7} feat: add d$`))
+		})
+
+		Describe("GetBaseBranch", func() {
+			BeforeEach(func() {
+				Expect(wt.Checkout(&git.CheckoutOptions{Branch: plumbing.NewBranchReferenceName("feature"), Create: true})).To(Succeed())
+			})
+
+			It("should use the default branch of origin", func() {
+				Expect(repo.Storer.SetReference(plumbing.NewSymbolicReference(
+					plumbing.NewRemoteHEADReferenceName("origin"), plumbing.NewRemoteReferenceName("origin", "develop")))).To(Succeed())
+				Expect(g.GetBaseBranch()).To(Equal("develop"))
+			})
+
+			It("should prefer the upstream when it is another branch", func() {
+				Expect(repo.Storer.SetReference(plumbing.NewSymbolicReference(
+					plumbing.NewRemoteHEADReferenceName("origin"), plumbing.NewRemoteReferenceName("origin", "develop")))).To(Succeed())
+				cfg, err := repo.Config()
+				Expect(err).NotTo(HaveOccurred())
+				cfg.Branches["feature"] = &config.Branch{Name: "feature", Remote: "origin", Merge: plumbing.NewBranchReferenceName("release/2.0")}
+				Expect(repo.SetConfig(cfg)).To(Succeed())
+				Expect(g.GetBaseBranch()).To(Equal("release/2.0"))
+			})
+
+			It("should fall back to common branch names", func() {
+				Expect(g.GetBaseBranch()).To(Equal("master"))
+			})
+		})
+
+		Context("with a remote", func() {
+			var remote *git.Repository
+
+			BeforeEach(func() {
+				var err error
+				remote, err = git.Init(memory.NewStorage(), nil)
+				Expect(err).NotTo(HaveOccurred())
+				client.InstallProtocol("file", server.NewClient(server.MapLoader{"file:///remote": remote.Storer}))
+				DeferCleanup(client.InstallProtocol, "file", file.DefaultClient)
+
+				_, err = repo.CreateRemote(&config.RemoteConfig{Name: "origin", URLs: []string{"file:///remote"}})
+				Expect(err).NotTo(HaveOccurred())
+			})
+
+			It("should push and set the upstream", func() {
+				Expect(g.Push()).To(Succeed())
+
+				head, err := repo.Head()
+				Expect(err).NotTo(HaveOccurred())
+				pushed, err := remote.Reference(plumbing.NewBranchReferenceName("master"), true)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(pushed.Hash()).To(Equal(head.Hash()))
+				Expect(g.GetRemoteBranchSHA()).To(Equal(head.Hash().String()))
+
+				cfg, err := repo.Config()
+				Expect(err).NotTo(HaveOccurred())
+				Expect(cfg.Branches["master"].Remote).To(Equal("origin"))
+			})
+
+			It("should reject a diverged push and force it with a lease", func() {
+				Expect(g.Push()).To(Succeed())
+				sha, err := g.GetRemoteBranchSHA()
+				Expect(err).NotTo(HaveOccurred())
+				Expect(g.IsAncestor(sha)).To(BeTrue())
+
+				write("c.txt", "new\n")
+				Expect(g.Amend("feat: rewritten")).To(Succeed())
+				Expect(g.IsAncestor(sha)).To(BeFalse())
+
+				err = g.Push()
+				var pushErr *PushError
+				Expect(errors.As(err, &pushErr)).To(BeTrue())
+				Expect(pushErr.Reason).To(Equal(PushRejected))
+
+				Expect(g.ForcePushWithLease("0000000000000000000000000000000000000001")).NotTo(Succeed())
+				Expect(g.ForcePushWithLease(sha)).To(Succeed())
+				head, err := repo.Head()
+				Expect(err).NotTo(HaveOccurred())
+				Expect(g.GetRemoteBranchSHA()).To(Equal(head.Hash().String()))
+			})
+		})
+	})
+
+	Describe("pushToken", func() {
+		BeforeEach(func() {
+			GinkgoT().Setenv("AIGIT_GIT_TOKEN", "")
+			GinkgoT().Setenv("GITHUB_TOKEN", "github-token")
+		})
+
+		It("should send GITHUB_TOKEN only to github.com over https", func() {
+			Expect(pushToken("https://github.com/acme/api.git")).To(Equal("github-token"))
+			Expect(pushToken("https://bitbucket.org/acme/api.git")).To(BeEmpty())
+			Expect(pushToken("http://github.com/acme/api.git")).To(BeEmpty())
+		})
+
+		It("should send AIGIT_GIT_TOKEN to any https remote", func() {
+			GinkgoT().Setenv("AIGIT_GIT_TOKEN", "git-token")
+			Expect(pushToken("https://git.example.com/acme/api.git")).To(Equal("git-token"))
+			Expect(pushToken("http://git.example.com/acme/api.git")).To(BeEmpty())
+			Expect(pushToken("git@git.example.com:acme/api.git")).To(BeEmpty())
+		})
+	})
+})
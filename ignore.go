@@ -0,0 +1,213 @@
+package aigit
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// IgnoreFile is the name of the file listing paths to leave out of prompts, in gitignore syntax
+const IgnoreFile = ".aigitignore"
+
+// defaultIgnore lists files that rarely help the model: lockfiles, generated and vendored code.
+// Rules in .aigitignore are applied after these, so a negation like !go.sum restores a file.
+const defaultIgnore = `
+go.sum
+package-lock.json
+yarn.lock
+pnpm-lock.yaml
+Cargo.lock
+poetry.lock
+Pipfile.lock
+Gemfile.lock
+composer.lock
+*.pb.go
+*_pb2.py
+*.min.js
+*.min.css
+*.snap
+__snapshots__/
+vendor/
+node_modules/
+`
+
+type ignoreRule struct {
+	pattern *regexp.Regexp
+	negate  bool
+	dirOnly bool
+}
+
+// IgnoreRules matches paths against gitignore style patterns
+type IgnoreRules struct {
+	rules []ignoreRule
+}
+
+// ParseIgnore parses gitignore style patterns, one per line
+func ParseIgnore(text string) *IgnoreRules {
+	r := &IgnoreRules{}
+	r.add(text)
+	return r
+}
+
+// LoadIgnore returns the built-in rules followed by the rules in the .aigitignore file in root, if any
+func LoadIgnore(root string) (*IgnoreRules, error) {
+	r := ParseIgnore(defaultIgnore)
+	if root == "" {
+		return r, nil
+	}
+	data, err := os.ReadFile(filepath.Join(root, IgnoreFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return r, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading %s: %w", IgnoreFile, err)
+	}
+	r.add(string(data))
+	return r, nil
+}
+
+func (r *IgnoreRules) add(text string) {
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimRight(line, " \t\r")
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if rule, ok := parseIgnoreRule(line); ok {
+			r.rules = append(r.rules, rule)
+		}
+	}
+}
+
+// parseIgnoreRule compiles a single gitignore style pattern. Malformed patterns are
+// reported as not ok, and skipped like git does.
+func parseIgnoreRule(line string) (ignoreRule, bool) {
+	rule := ignoreRule{}
+	if strings.HasPrefix(line, "!") {
+		rule.negate = true
+		line = line[1:]
+	} else if strings.HasPrefix(line, `\`) {
+		line = line[1:]
+	}
+	if strings.HasSuffix(line, "/") {
+		rule.dirOnly = true
+		line = strings.TrimSuffix(line, "/")
+	}
+
+	// Patterns containing a slash are relative to the root, others match at any depth
+	anchored := strings.Contains(line, "/")
+	line = strings.TrimPrefix(line, "/")
+
+	expr := globToRegexp(line)
+	if anchored {
+		expr = "^" + expr + "$"
+	} else {
+		expr = "^(?:.*/)?" + expr + "$"
+	}
+	pattern, err := regexp.Compile(expr)
+	if err != nil {
+		return rule, false
+	}
+	rule.pattern = pattern
+	return rule, true
+}
+
+// Match reports whether a file path is ignored. A file is also ignored when one of
+// its parent directories is. The last matching rule wins.
+func (r *IgnoreRules) Match(path string) bool {
+	ignored := false
+	for _, rule := range r.rules {
+		if rule.match(path) {
+			ignored = !rule.negate
+		}
+	}
+	return ignored
+}
+
+// match reports whether the rule matches a file path or one of its parent directories
+func (rule ignoreRule) match(path string) bool {
+	path = filepath.ToSlash(strings.TrimPrefix(path, "/"))
+	parts := strings.Split(path, "/")
+	for i := 1; i < len(parts); i++ {
+		if rule.pattern.MatchString(strings.Join(parts[:i], "/")) {
+			return true
+		}
+	}
+	return !rule.dirOnly && rule.pattern.MatchString(path)
+}
+
+// globToRegexp translates gitignore glob syntax into a regular expression
+func globToRegexp(glob string) string {
+	var b strings.Builder
+	for i := 0; i < len(glob); i++ {
+		c := glob[i]
+		switch {
+		case strings.HasPrefix(glob[i:], "**/"):
+			b.WriteString("(?:.*/)?")
+			i += 2
+		case strings.HasPrefix(glob[i:], "/**") && i+3 == len(glob):
+			b.WriteString("/.*")
+			i += 2
+		case strings.HasPrefix(glob[i:], "**"):
+			b.WriteString(".*")
+			i++
+		case c == '*':
+			b.WriteString("[^/]*")
+		case c == '?':
+			b.WriteString("[^/]")
+		case c == '[':
+			end := strings.IndexByte(glob[i+1:], ']')
+			if end < 0 {
+				b.WriteString(`\[`)
+				continue
+			}
+			class := glob[i+1 : i+1+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			b.WriteString("[" + class + "]")
+			i += end + 1
+		case c == '\\' && i+1 < len(glob):
+			i++
+			b.WriteString(regexp.QuoteMeta(string(glob[i])))
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	return b.String()
+}
+
+// filterDiff removes ignored files from a diff, replacing each with a one line note
+// so the model still knows it changed. It returns the filtered diff and the removed paths.
+func filterDiff(diff string, rules *IgnoreRules) (string, []string) {
+	var kept strings.Builder
+	var notes, removed []string
+	for _, file := range parseDiff(diff) {
+		if file.Path == "" || !rules.Match(file.Path) {
+			kept.WriteString(file.String())
+			continue
+		}
+		removed = append(removed, file.Path)
+		switch {
+		case strings.Contains(file.Header, "\nnew file mode"):
+			notes = append(notes, "added "+file.Path)
+		case strings.Contains(file.Header, "\ndeleted file mode"):
+			notes = append(notes, "deleted "+file.Path)
+		default:
+			notes = append(notes, "updated "+file.Path)
+		}
+	}
+	if len(notes) == 0 {
+		return diff, nil
+	}
+	if kept.Len() > 0 {
+		kept.WriteString("\n")
+	}
+	kept.WriteString("Other changed files, contents omitted:\n")
+	for _, note := range notes {
+		kept.WriteString(note + "\n")
+	}
+	return kept.String(), removed
+}
@@ -0,0 +1,54 @@
+package aigit
+
+import (
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("IgnoreRules", func() {
+	DescribeTable("matching gitignore patterns",
+		func(patterns, path string, expected bool) {
+			Expect(ParseIgnore(patterns).Match(path)).To(Equal(expected))
+		},
+		Entry("basename at root", "go.sum", "go.sum", true),
+		Entry("basename nested", "go.sum", "tools/go.sum", true),
+		Entry("wildcard", "*.pb.go", "api/v1/service.pb.go", true),
+		Entry("wildcard does not cross directories", "api/*.go", "api/v1/service.go", false),
+		Entry("anchored", "/gen", "gen/file.go", true),
+		Entry("anchored does not match nested", "/gen", "src/gen/file.go", false),
+		Entry("directory", "vendor/", "vendor/github.com/x/y.go", true),
+		Entry("directory only matches directories", "vendor/", "vendor", false),
+		Entry("double star prefix", "**/testdata", "a/b/testdata/x.json", true),
+		Entry("double star suffix", "docs/**", "docs/a/b.md", true),
+		Entry("double star middle", "a/**/z.txt", "a/b/c/z.txt", true),
+		Entry("character class", "file[0-9].txt", "file7.txt", true),
+		Entry("negation", "*.lock\n!keep.lock", "keep.lock", false),
+		Entry("comments and blanks", "# comment\n\n*.snap", "ui/__snapshots__/a.snap", true),
+		Entry("unmatched", "*.lock", "main.go", false),
+	)
+
+	It("should let .aigitignore override the defaults", func() {
+		root := GinkgoT().TempDir()
+		Expect(os.WriteFile(filepath.Join(root, IgnoreFile), []byte("!go.sum\n*.generated.ts\n"), 0o644)).To(Succeed())
+
+		rules, err := LoadIgnore(root)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(rules.Match("go.sum")).To(BeFalse())
+		Expect(rules.Match("package-lock.json")).To(BeTrue())
+		Expect(rules.Match("web/api.generated.ts")).To(BeTrue())
+	})
+
+	It("should replace ignored files in a diff with a note", func() {
+		diff := fileDiff("main.go", 1, 1) + fileDiff("go.sum", 1, 50) +
+			"diff --git a/gen/x.pb.go b/gen/x.pb.go\nnew file mode 100644\n--- /dev/null\n+++ b/gen/x.pb.go\n@@ -0,0 +1 @@\n+package gen\n"
+
+		filtered, removed := filterDiff(diff, ParseIgnore(defaultIgnore))
+		Expect(removed).To(Equal([]string{"go.sum", "gen/x.pb.go"}))
+		Expect(filtered).To(HavePrefix(fileDiff("main.go", 1, 1)))
+		Expect(filtered).To(HaveSuffix("Other changed files, contents omitted:\nupdated go.sum\nadded gen/x.pb.go\n"))
+		Expect(filtered).NotTo(ContainSubstring("line 0 of hunk 0 in go.sum"))
+	})
+})
@@ -2,8 +2,10 @@ package aigit
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
+	"strings"
 
 	"github.com/anthropics/anthropic-sdk-go"
 )
@@ -12,36 +14,204 @@ type Model interface {
 	Query(ctx context.Context, query string) (string, error)
 }
 
-func GetDefaultModel() Model {
-	if _, exists := os.LookupEnv("ANTHROPIC_API_KEY"); exists {
-		return NewAnthropicModel()
+// StreamingModel is a Model that can deliver its answer while it is being generated
+type StreamingModel interface {
+	Model
+	// QueryStream calls onDelta with each piece of text as it arrives and returns the complete answer
+	QueryStream(ctx context.Context, query string, onDelta func(delta string)) (string, error)
+}
+
+var (
+	ErrNoModel          = errors.New("no model configured: set ANTHROPIC_API_KEY, OPENAI_API_KEY, OLLAMA_HOST or LLAMACPP_HOST")
+	ErrUnknownProvider  = errors.New("unknown model provider")
+	ErrMissingAPIKey    = errors.New("API key is not set")
+	ErrProviderConflict = errors.New("model provider is already registered")
+)
+
+// DefaultMaxTokens is used when ModelOptions does not set a token limit
+const DefaultMaxTokens = 1024
+
+// ModelOptions tunes a model for a single command
+type ModelOptions struct {
+	// Provider selects a registered provider. When empty, one is detected from the environment.
+	Provider string
+	// Name is the provider specific model name. When empty, the provider default is used.
+	Name        string
+	MaxTokens   int
+	Temperature float64
+	// Endpoints maps provider names to the server URL to use, overriding the environment.
+	// A provider with an endpoint is detected as configured.
+	Endpoints map[string]string
+}
+
+func (o ModelOptions) maxTokens() int {
+	if o.MaxTokens <= 0 {
+		return DefaultMaxTokens
+	}
+	return o.MaxTokens
+}
+
+// ModelFactory constructs a model on demand, so commands that never query
+// a model don't require one to be configured
+type ModelFactory func(opts ModelOptions) (Model, error)
+
+// Provider is a named model backend that can be selected explicitly or detected from the environment
+type Provider struct {
+	// Name is used to select the provider through the model.provider setting
+	Name string
+	// Detect reports whether the environment configures this provider
+	Detect func() bool
+	// New constructs a model for this provider
+	New ModelFactory
+}
+
+// providers holds the registered backends in detection order
+var providers []Provider
+
+func init() {
+	// Cloud providers are registered first so local servers are only used when no credentials exist
+	RegisterProvider(Provider{
+		Name:   "anthropic",
+		Detect: envSet("ANTHROPIC_API_KEY"),
+		New: func(opts ModelOptions) (Model, error) {
+			if !envSet("ANTHROPIC_API_KEY")() {
+				return nil, fmt.Errorf("anthropic: ANTHROPIC_API_KEY: %w", ErrMissingAPIKey)
+			}
+			if opts.Temperature > 1 {
+				return nil, fmt.Errorf("anthropic: temperature must be between 0 and 1, got %g", opts.Temperature)
+			}
+			return NewAnthropicModel(opts), nil
+		},
+	})
+	RegisterProvider(Provider{
+		Name:   "openai",
+		Detect: envSet("OPENAI_API_KEY", "OPENAI_BASE_URL"),
+		New:    func(opts ModelOptions) (Model, error) { return NewOpenAIModelFromEnv(opts), nil },
+	})
+	RegisterProvider(Provider{
+		Name:   "llamacpp",
+		Detect: envSet("LLAMACPP_HOST"),
+		New:    func(opts ModelOptions) (Model, error) { return NewLlamaCppModelFromEnv(opts), nil },
+	})
+	RegisterProvider(Provider{
+		Name: "ollama",
+		Detect: func() bool {
+			return envSet("OLLAMA_HOST")() || ollamaRunning()
+		},
+		New: func(opts ModelOptions) (Model, error) { return NewOllamaModelFromEnv(opts), nil },
+	})
+}
+
+// RegisterProvider adds a model backend to the registry
+func RegisterProvider(provider Provider) error {
+	for _, p := range providers {
+		if p.Name == provider.Name {
+			return fmt.Errorf("%w: %s", ErrProviderConflict, provider.Name)
+		}
+	}
+	providers = append(providers, provider)
+	return nil
+}
+
+// Providers returns the names of all registered providers in detection order
+func Providers() []string {
+	names := make([]string, len(providers))
+	for i, p := range providers {
+		names[i] = p.Name
+	}
+	return names
+}
+
+// NewModel constructs a model using the named provider
+func NewModel(name string, opts ModelOptions) (Model, error) {
+	for _, p := range providers {
+		if p.Name == name {
+			return p.New(opts)
+		}
+	}
+	return nil, fmt.Errorf("%w: %q (available: %s)", ErrUnknownProvider, name, strings.Join(Providers(), ", "))
+}
+
+// GetDefaultModel picks a model backend. opts.Provider selects one explicitly,
+// otherwise the first registered provider detected in the environment is used.
+func GetDefaultModel(opts ModelOptions) (Model, error) {
+	if opts.Provider != "" {
+		return NewModel(opts.Provider, opts)
+	}
+	for _, p := range providers {
+		if opts.Endpoints[p.Name] != "" || p.Detect != nil && p.Detect() {
+			return p.New(opts)
+		}
+	}
+	return nil, ErrNoModel
+}
+
+// envSet returns a detector reporting whether any of the given environment variables are set
+func envSet(keys ...string) func() bool {
+	return func() bool {
+		for _, key := range keys {
+			if _, exists := os.LookupEnv(key); exists {
+				return true
+			}
+		}
+		return false
 	}
-	panic("No model configured.")
 }
 
 type AnthropicModel struct {
 	client anthropic.Client
+	opts   ModelOptions
 }
 
-func NewAnthropicModel() *AnthropicModel {
+func NewAnthropicModel(opts ModelOptions) *AnthropicModel {
+	if opts.Name == "" {
+		opts.Name = string(anthropic.ModelClaude3_7SonnetLatest)
+	}
 	return &AnthropicModel{
 		client: anthropic.NewClient(),
+		opts:   opts,
 	}
 }
 
-func (m *AnthropicModel) Query(ctx context.Context, query string) (string, error) {
-	message, err := m.client.Messages.New(ctx, anthropic.MessageNewParams{
-		MaxTokens: 1024,
+func (m *AnthropicModel) params(query string) anthropic.MessageNewParams {
+	return anthropic.MessageNewParams{
+		MaxTokens:   int64(m.opts.maxTokens()),
+		Temperature: anthropic.Float(m.opts.Temperature),
 		Messages: []anthropic.MessageParam{{
 			Content: []anthropic.ContentBlockParamUnion{{
 				OfText: &anthropic.TextBlockParam{Text: query},
 			}},
 			Role: anthropic.MessageParamRoleUser,
 		}},
-		Model: anthropic.ModelClaude3_7SonnetLatest,
-	})
+		Model: anthropic.Model(m.opts.Name),
+	}
+}
+
+func (m *AnthropicModel) Query(ctx context.Context, query string) (string, error) {
+	message, err := m.client.Messages.New(ctx, m.params(query))
 	if err != nil {
 		return "", fmt.Errorf("failed to query model: %w", err)
 	}
 	return message.Content[0].Text, nil
 }
+
+func (m *AnthropicModel) QueryStream(ctx context.Context, query string, onDelta func(delta string)) (string, error) {
+	stream := m.client.Messages.NewStreaming(ctx, m.params(query))
+	defer stream.Close()
+
+	var text strings.Builder
+	for stream.Next() {
+		event, ok := stream.Current().AsAny().(anthropic.ContentBlockDeltaEvent)
+		if !ok {
+			continue
+		}
+		if delta, ok := event.Delta.AsAny().(anthropic.TextDelta); ok {
+			text.WriteString(delta.Text)
+			onDelta(delta.Text)
+		}
+	}
+	if err := stream.Err(); err != nil {
+		return "", fmt.Errorf("failed to query model: %w", err)
+	}
+	return text.String(), nil
+}
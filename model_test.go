@@ -0,0 +1,42 @@
+package aigit
+
+import (
+	"os"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Model registry", func() {
+	It("should list built-in providers in detection order", func() {
+		Expect(Providers()).To(Equal([]string{"anthropic", "openai", "llamacpp", "ollama"}))
+	})
+
+	It("should reject duplicate provider names", func() {
+		err := RegisterProvider(Provider{Name: "anthropic"})
+		Expect(err).To(MatchError(ErrProviderConflict))
+	})
+
+	It("should return an error for unknown providers", func() {
+		_, err := GetDefaultModel(ModelOptions{Provider: "nope"})
+		Expect(err).To(MatchError(ErrUnknownProvider))
+	})
+
+	It("should return an error when an explicit provider lacks credentials", func() {
+		GinkgoT().Setenv("ANTHROPIC_API_KEY", "")
+		os.Unsetenv("ANTHROPIC_API_KEY")
+		_, err := GetDefaultModel(ModelOptions{Provider: "anthropic"})
+		Expect(err).To(MatchError(ErrMissingAPIKey))
+	})
+
+	It("should use the provider of a configured endpoint", func() {
+		for _, key := range []string{"ANTHROPIC_API_KEY", "OPENAI_API_KEY", "OPENAI_BASE_URL"} {
+			GinkgoT().Setenv(key, "")
+			os.Unsetenv(key)
+		}
+		model, err := GetDefaultModel(ModelOptions{Endpoints: map[string]string{"openai": "http://gateway.test/v1/"}})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(model).To(BeAssignableToTypeOf(&OpenAIModel{}))
+		Expect(model.(*OpenAIModel).baseURL).To(Equal("http://gateway.test/v1"))
+	})
+})
@@ -0,0 +1,147 @@
+package aigit
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	DefaultOllamaHost  = "http://127.0.0.1:11434"
+	DefaultOllamaModel = "llama3.2"
+)
+
+// OllamaModel implements Model using the chat API of a local Ollama server
+type OllamaModel struct {
+	client *http.Client
+	host   string
+	opts   ModelOptions
+}
+
+// NewOllamaModel creates a model talking to the Ollama server at host
+func NewOllamaModel(host string, opts ModelOptions) *OllamaModel {
+	if host == "" {
+		host = DefaultOllamaHost
+	}
+	if opts.Name == "" {
+		opts.Name = DefaultOllamaModel
+	}
+	return &OllamaModel{
+		client: http.DefaultClient,
+		host:   normalizeHost(host),
+		opts:   opts,
+	}
+}
+
+// NewOllamaModelFromEnv creates an Ollama model configured by OLLAMA_HOST and OLLAMA_MODEL.
+// A model name or ollama endpoint in opts takes precedence.
+func NewOllamaModelFromEnv(opts ModelOptions) *OllamaModel {
+	if opts.Name == "" {
+		opts.Name = os.Getenv("OLLAMA_MODEL")
+	}
+	return NewOllamaModel(endpoint(opts, "ollama", "OLLAMA_HOST"), opts)
+}
+
+// NewLlamaCppModelFromEnv creates a model for a llama.cpp server configured by LLAMACPP_HOST.
+// llama.cpp serves the OpenAI chat completions protocol, so this is an OpenAIModel without a key.
+// A model name or llamacpp endpoint in opts takes precedence.
+func NewLlamaCppModelFromEnv(opts ModelOptions) *OpenAIModel {
+	if opts.Name == "" {
+		opts.Name = os.Getenv("LLAMACPP_MODEL")
+	}
+	return NewOpenAIModel(normalizeHost(endpoint(opts, "llamacpp", "LLAMACPP_HOST"))+"/v1", "", opts)
+}
+
+// endpoint returns the server URL of a provider from opts, or else from an environment variable
+func endpoint(opts ModelOptions, provider, env string) string {
+	if url := opts.Endpoints[provider]; url != "" {
+		return url
+	}
+	return os.Getenv(env)
+}
+
+type ollamaRequest struct {
+	Model    string          `json:"model"`
+	Messages []openAIMessage `json:"messages"`
+	Stream   bool            `json:"stream"`
+	Options  ollamaOptions   `json:"options"`
+}
+
+type ollamaOptions struct {
+	NumPredict  int     `json:"num_predict"`
+	Temperature float64 `json:"temperature"`
+}
+
+type ollamaResponse struct {
+	Message openAIMessage `json:"message"`
+	Error   string        `json:"error,omitempty"`
+}
+
+func (m *OllamaModel) Query(ctx context.Context, query string) (string, error) {
+	body, err := json.Marshal(ollamaRequest{
+		Model:    m.opts.Name,
+		Messages: []openAIMessage{{Role: "user", Content: query}},
+		Stream:   false,
+		Options: ollamaOptions{
+			NumPredict:  m.opts.maxTokens(),
+			Temperature: m.opts.Temperature,
+		},
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to encode request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, m.host+"/api/chat", bytes.NewReader(body))
+	if err != nil {
+		return "", fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := m.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to query model: %w", err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("failed to read response: %w", err)
+	}
+
+	var result ollamaResponse
+	if err := json.Unmarshal(data, &result); err != nil {
+		return "", fmt.Errorf("failed to decode response (status %d): %w", resp.StatusCode, err)
+	}
+	if result.Error != "" {
+		return "", fmt.Errorf("failed to query model: %s", result.Error)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("failed to query model: status %d", resp.StatusCode)
+	}
+	return result.Message.Content, nil
+}
+
+// ollamaRunning reports whether an Ollama server answers at the default address
+func ollamaRunning() bool {
+	client := http.Client{Timeout: 250 * time.Millisecond}
+	resp, err := client.Get(DefaultOllamaHost + "/api/tags")
+	if err != nil {
+		return false
+	}
+	resp.Body.Close()
+	return resp.StatusCode == http.StatusOK
+}
+
+// normalizeHost adds a scheme to bare host:port addresses and strips trailing slashes
+func normalizeHost(host string) string {
+	if !strings.Contains(host, "://") {
+		host = "http://" + host
+	}
+	return strings.TrimSuffix(host, "/")
+}
@@ -0,0 +1,61 @@
+package aigit
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("OllamaModel", func() {
+	var server *httptest.Server
+
+	BeforeEach(func() {
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			Expect(r.URL.Path).To(Equal("/api/chat"))
+
+			var req ollamaRequest
+			Expect(json.NewDecoder(r.Body).Decode(&req)).To(Succeed())
+			Expect(req.Stream).To(BeFalse())
+			if req.Model != "llama3.2" {
+				w.WriteHeader(http.StatusNotFound)
+				w.Write([]byte(`{"error":"model not found"}`))
+				return
+			}
+			w.Write([]byte(`{"message":{"role":"assistant","content":"fix: offline commit"},"done":true}`))
+		}))
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	It("should return the assistant message", func() {
+		model := NewOllamaModel(server.URL, ModelOptions{})
+		answer, err := model.Query(context.Background(), "hello")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(answer).To(Equal("fix: offline commit"))
+	})
+
+	It("should surface server errors", func() {
+		model := NewOllamaModel(server.URL, ModelOptions{Name: "missing"})
+		_, err := model.Query(context.Background(), "hello")
+		Expect(err).To(MatchError(ContainSubstring("model not found")))
+	})
+
+	It("should accept hosts without a scheme", func() {
+		Expect(normalizeHost("localhost:11434/")).To(Equal("http://localhost:11434"))
+	})
+
+	It("should prefer configured endpoints over the environment", func() {
+		GinkgoT().Setenv("OLLAMA_HOST", "env.test:11434")
+		GinkgoT().Setenv("LLAMACPP_HOST", "env.test:8080")
+		endpoints := map[string]string{"ollama": "config.test:11434", "llamacpp": "config.test:8080"}
+		Expect(NewOllamaModelFromEnv(ModelOptions{Endpoints: endpoints}).host).To(Equal("http://config.test:11434"))
+		Expect(NewLlamaCppModelFromEnv(ModelOptions{Endpoints: endpoints}).baseURL).To(Equal("http://config.test:8080/v1"))
+		Expect(NewOllamaModelFromEnv(ModelOptions{}).host).To(Equal("http://env.test:11434"))
+	})
+})
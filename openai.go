@@ -0,0 +1,120 @@
+package aigit
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+)
+
+const (
+	DefaultOpenAIBaseURL = "https://api.openai.com/v1"
+	DefaultOpenAIModel   = "gpt-4o-mini"
+)
+
+// OpenAIModel implements Model against any server speaking the OpenAI chat completions protocol
+type OpenAIModel struct {
+	client  *http.Client
+	baseURL string
+	apiKey  string
+	opts    ModelOptions
+}
+
+// NewOpenAIModel creates a model talking to the chat completions endpoint at baseURL.
+// An empty apiKey omits the Authorization header, which suits unauthenticated local gateways.
+func NewOpenAIModel(baseURL, apiKey string, opts ModelOptions) *OpenAIModel {
+	if baseURL == "" {
+		baseURL = DefaultOpenAIBaseURL
+	}
+	if opts.Name == "" {
+		opts.Name = DefaultOpenAIModel
+	}
+	return &OpenAIModel{
+		client:  http.DefaultClient,
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		apiKey:  apiKey,
+		opts:    opts,
+	}
+}
+
+// NewOpenAIModelFromEnv creates an OpenAI-compatible model configured by
+// OPENAI_BASE_URL, OPENAI_API_KEY and OPENAI_MODEL. A model name or openai endpoint in
+// opts takes precedence.
+func NewOpenAIModelFromEnv(opts ModelOptions) *OpenAIModel {
+	if opts.Name == "" {
+		opts.Name = os.Getenv("OPENAI_MODEL")
+	}
+	return NewOpenAIModel(endpoint(opts, "openai", "OPENAI_BASE_URL"), os.Getenv("OPENAI_API_KEY"), opts)
+}
+
+type openAIMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+type openAIRequest struct {
+	Model       string          `json:"model"`
+	Messages    []openAIMessage `json:"messages"`
+	MaxTokens   int             `json:"max_tokens,omitempty"`
+	Temperature float64         `json:"temperature"`
+}
+
+type openAIResponse struct {
+	Choices []struct {
+		Message openAIMessage `json:"message"`
+	} `json:"choices"`
+	Error *struct {
+		Message string `json:"message"`
+	} `json:"error,omitempty"`
+}
+
+func (m *OpenAIModel) Query(ctx context.Context, query string) (string, error) {
+	body, err := json.Marshal(openAIRequest{
+		Model:       m.opts.Name,
+		Messages:    []openAIMessage{{Role: "user", Content: query}},
+		MaxTokens:   m.opts.maxTokens(),
+		Temperature: m.opts.Temperature,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to encode request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, m.baseURL+"/chat/completions", bytes.NewReader(body))
+	if err != nil {
+		return "", fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if m.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+m.apiKey)
+	}
+
+	resp, err := m.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to query model: %w", err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("failed to read response: %w", err)
+	}
+
+	var result openAIResponse
+	if err := json.Unmarshal(data, &result); err != nil {
+		return "", fmt.Errorf("failed to decode response (status %d): %w", resp.StatusCode, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		if result.Error != nil {
+			return "", fmt.Errorf("failed to query model: %s (status %d)", result.Error.Message, resp.StatusCode)
+		}
+		return "", fmt.Errorf("failed to query model: status %d", resp.StatusCode)
+	}
+	if len(result.Choices) == 0 {
+		return "", fmt.Errorf("model returned no choices")
+	}
+	return result.Choices[0].Message.Content, nil
+}
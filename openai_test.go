@@ -0,0 +1,69 @@
+package aigit
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("OpenAIModel", func() {
+	var (
+		server  *httptest.Server
+		handler http.HandlerFunc
+	)
+
+	BeforeEach(func() {
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			handler(w, r)
+		}))
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	Context("when the server answers", func() {
+		BeforeEach(func() {
+			handler = func(w http.ResponseWriter, r *http.Request) {
+				Expect(r.URL.Path).To(Equal("/v1/chat/completions"))
+				Expect(r.Header.Get("Authorization")).To(Equal("Bearer secret"))
+
+				var req openAIRequest
+				Expect(json.NewDecoder(r.Body).Decode(&req)).To(Succeed())
+				Expect(req.Model).To(Equal("gateway-model"))
+				Expect(req.Messages).To(HaveLen(1))
+				Expect(req.Messages[0].Content).To(Equal("hello"))
+
+				w.Header().Set("Content-Type", "application/json")
+				w.Write([]byte(`{"choices":[{"message":{"role":"assistant","content":"feat: add thing"}}]}`))
+			}
+		})
+
+		It("should return the first choice", func() {
+			model := NewOpenAIModel(server.URL+"/v1/", "secret", ModelOptions{Name: "gateway-model"})
+			answer, err := model.Query(context.Background(), "hello")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(answer).To(Equal("feat: add thing"))
+		})
+	})
+
+	Context("when the server returns an error", func() {
+		BeforeEach(func() {
+			handler = func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusUnauthorized)
+				w.Write([]byte(`{"error":{"message":"invalid api key"}}`))
+			}
+		})
+
+		It("should surface the error message", func() {
+			model := NewOpenAIModel(server.URL, "wrong", ModelOptions{Name: "gateway-model"})
+			_, err := model.Query(context.Background(), "hello")
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("invalid api key"))
+		})
+	})
+})
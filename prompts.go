@@ -0,0 +1,138 @@
+package aigit
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"text/template"
+)
+
+var ErrUnknownPrompt = errors.New("unknown prompt")
+
+const (
+	PromptCommit  = "commit"
+	PromptAmend   = "amend"
+	PromptPR      = "pr"
+	PromptPRTitle = "pr-title"
+	// PromptSummarize condenses one chunk of a diff that is too large to send at once
+	PromptSummarize = "summarize"
+	// PromptReview asks for code review findings as JSON
+	PromptReview = "review"
+)
+
+// PromptData holds the variables available to prompt templates
+type PromptData struct {
+	// Diff is the staged diff for commit and amend prompts, or the diff of the branch for pull request prompts
+	Diff string
+	// Summarized is true when Diff holds summaries of a diff too large to send in full
+	Summarized bool
+	// History holds the messages of the commits on the branch, for pull request prompts
+	History string
+	// Template is the repository's pull request template, for pull request prompts
+	Template string
+	// Stats lists the files changed on the branch with lines added and removed, for pull request prompts
+	Stats string
+	// Description is the generated pull request description, for the title prompt
+	Description string
+	// Branch is the name of the current branch
+	Branch string
+	// Ticket is the ticket ID found in the branch name, if any
+	Ticket string
+	// Files lists the staged files
+	Files []string
+	// RecentCommits lists the subjects of the most recent commits
+	RecentCommits []string
+}
+
+// defaultPrompts holds the built-in templates by name
+var defaultPrompts = map[string]string{
+	PromptCommit: "Please write a concise and descriptive commit message, adhering to conventional commits and in plain text, for the following changes:\n\n{{.Diff}}",
+	PromptAmend:  "Please write a concise and descriptive commit message, adhering to conventional commits and in plain text, for the following changes:\n\n{{.Diff}}",
+	PromptPR: "Please write a concise and descriptive pull request description for the following changes. Include a summary of the changes and any important notes for reviewers.\n\n" +
+		"Commits:\n\n{{.History}}\n\n" +
+		"{{if .Stats}}Changed files:\n\n{{.Stats}}\n{{end}}" +
+		"{{if .Diff}}{{if .Summarized}}Summary of the changes, which are too large to include in full{{else}}Changes{{end}}:\n\n{{.Diff}}\n\n{{end}}" +
+		"{{if .Template}}Write the description following this template. Keep every heading of the template, in order, " +
+		"and fill in each section:\n\n{{.Template}}{{end}}",
+	PromptPRTitle: "Based on this pull request description, generate a concise, descriptive title (max 72 chars) that follows conventional commits format. Return only the title, no markdown or quotes:\n\n{{.Description}}",
+	PromptSummarize: "The following is one part of a larger set of changes, touching {{join .Files \", \"}}. " +
+		"Summarize what it changes and why, as a few short plain text bullet points. Mention file names, " +
+		"but do not write a commit message:\n\n{{.Diff}}",
+	PromptReview: "Review the following changes as an experienced code reviewer. Point out bugs, security issues, " +
+		"missing error handling and confusing code. Do not comment on formatting or praise the changes.\n\n" +
+		"{{if .History}}Commits:\n\n{{.History}}\n\n{{end}}" +
+		"{{if .Summarized}}Summary of the changes, which are too large to include in full{{else}}Changes, with each " +
+		"line prefixed by its line number in the new version of the file{{end}}:\n\n{{.Diff}}\n\n" +
+		"Answer with only a JSON array of findings, or [] if there are none. Each finding is an object with the fields " +
+		`"file" (the path in the new version), "start_line" and "end_line" (line numbers in the new version), ` +
+		`"severity" ("info", "warning" or "error") and "message".`,
+}
+
+// PromptNames returns the names of all prompts in display order
+func PromptNames() []string {
+	return []string{PromptCommit, PromptAmend, PromptPR, PromptPRTitle, PromptSummarize, PromptReview}
+}
+
+// Prompts loads prompt templates, preferring overrides from a directory over the built-in defaults
+type Prompts struct {
+	dir string
+}
+
+// NewPrompts creates a prompt loader reading overrides named <prompt>.tmpl from dir
+func NewPrompts(dir string) *Prompts {
+	return &Prompts{dir: dir}
+}
+
+// Source returns the template text of a prompt and where it was loaded from
+func (p *Prompts) Source(name string) (text, source string, err error) {
+	text, ok := defaultPrompts[name]
+	if !ok {
+		return "", "", fmt.Errorf("%w: %s (available: %s)", ErrUnknownPrompt, name, strings.Join(PromptNames(), ", "))
+	}
+	if p.dir == "" {
+		return text, "built-in", nil
+	}
+
+	path := filepath.Join(p.dir, name+".tmpl")
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return text, "built-in", nil
+	}
+	if err != nil {
+		return "", "", fmt.Errorf("error reading prompt template: %w", err)
+	}
+	return string(data), path, nil
+}
+
+// Render executes the named prompt template with the given data
+func (p *Prompts) Render(name string, data PromptData) (string, error) {
+	text, source, err := p.Source(name)
+	if err != nil {
+		return "", err
+	}
+
+	tmpl, err := template.New(name).Option("missingkey=error").Funcs(template.FuncMap{
+		"join": strings.Join,
+	}).Parse(text)
+	if err != nil {
+		return "", fmt.Errorf("error parsing prompt template %s: %w", source, err)
+	}
+
+	var out strings.Builder
+	if err := tmpl.Execute(&out, data); err != nil {
+		return "", fmt.Errorf("error rendering prompt template %s: %w", source, err)
+	}
+	return out.String(), nil
+}
+
+// extractTicket finds the first ticket ID in a branch name
+func extractTicket(branch, pattern string) string {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return ""
+	}
+	return re.FindString(branch)
+}
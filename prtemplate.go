@@ -0,0 +1,140 @@
+package aigit
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// PRTemplateNone disables pull request templates when set as pr.template
+const PRTemplateNone = "none"
+
+// maxTemplateRetries limits how often a description missing template sections is regenerated
+const maxTemplateRetries = 2
+
+// prTemplateDirs are the directories GitHub looks in for pull request templates, relative to the
+// repository root. Each may hold a pull_request_template.md, or a PULL_REQUEST_TEMPLATE directory
+// of several templates.
+var prTemplateDirs = []string{".github", "", "docs"}
+
+// MissingSectionsError is returned when a generated description leaves out headings of the template
+type MissingSectionsError struct {
+	Template string
+	Missing  []string
+}
+
+func (e *MissingSectionsError) Error() string {
+	return fmt.Sprintf("generated description is missing sections of %s: %s", e.Template, strings.Join(e.Missing, ", "))
+}
+
+// FindPRTemplates finds the pull request templates of a repository. The default template is the
+// single pull_request_template.md file, if any, and templates lists the files in
+// PULL_REQUEST_TEMPLATE directories. Names are matched case-insensitively, like GitHub does.
+func FindPRTemplates(root string) (defaultTemplate string, templates []string, err error) {
+	for _, dir := range prTemplateDirs {
+		dir = filepath.Join(root, dir)
+		entries, err := os.ReadDir(dir)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return "", nil, fmt.Errorf("error looking for pull request templates: %w", err)
+		}
+
+		for _, entry := range entries {
+			name := strings.ToLower(entry.Name())
+			switch {
+			case !entry.IsDir() && defaultTemplate == "" && (name == "pull_request_template.md" || name == "pull_request_template.txt"):
+				defaultTemplate = filepath.Join(dir, entry.Name())
+			case entry.IsDir() && name == "pull_request_template":
+				found, err := filepath.Glob(filepath.Join(dir, entry.Name(), "*.md"))
+				if err != nil {
+					return "", nil, fmt.Errorf("error looking for pull request templates: %w", err)
+				}
+				sort.Strings(found)
+				templates = append(templates, found...)
+			}
+		}
+	}
+	return defaultTemplate, templates, nil
+}
+
+// checkInsideDir returns an error unless path, with symlinks resolved, is inside dir
+func checkInsideDir(dir, path string) error {
+	resolved, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return fmt.Errorf("error resolving %s: %w", path, err)
+	}
+	if dir, err = filepath.EvalSymlinks(dir); err != nil {
+		return fmt.Errorf("error resolving %s: %w", dir, err)
+	}
+	rel, err := filepath.Rel(dir, resolved)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return fmt.Errorf("%s is outside of %s", path, dir)
+	}
+	return nil
+}
+
+// readPRTemplate reads a pull request template
+func readPRTemplate(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("error reading pull request template: %w", err)
+	}
+	return string(data), nil
+}
+
+// templateHeadings returns the markdown headings of a template, ignoring code blocks
+func templateHeadings(text string) []string {
+	var headings []string
+	fenced := false
+	for _, line := range strings.Split(text, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~") {
+			fenced = !fenced
+			continue
+		}
+		if heading, ok := markdownHeading(line); ok && !fenced {
+			headings = append(headings, heading)
+		}
+	}
+	return headings
+}
+
+// missingHeadings returns the headings absent from a description, compared case-insensitively
+// and regardless of heading level
+func missingHeadings(headings []string, description string) []string {
+	present := map[string]bool{}
+	for _, heading := range templateHeadings(description) {
+		present[normalizeHeading(heading)] = true
+	}
+	var missing []string
+	for _, heading := range headings {
+		if !present[normalizeHeading(heading)] {
+			missing = append(missing, heading)
+		}
+	}
+	return missing
+}
+
+// markdownHeading parses an ATX heading such as "## Testing", returning its text
+func markdownHeading(line string) (string, bool) {
+	// Up to three spaces of indentation are allowed
+	trimmed := strings.TrimLeft(line, " ")
+	if len(line)-len(trimmed) > 3 || !strings.HasPrefix(trimmed, "#") {
+		return "", false
+	}
+	text := strings.TrimLeft(trimmed, "#")
+	if level := len(trimmed) - len(text); level > 6 || (text != "" && text[0] != ' ' && text[0] != '\t') {
+		return "", false
+	}
+	text = strings.TrimSpace(strings.TrimRight(strings.TrimSpace(text), "#"))
+	return text, text != ""
+}
+
+func normalizeHeading(heading string) string {
+	return strings.ToLower(strings.TrimRight(strings.TrimSpace(heading), ":"))
+}
@@ -0,0 +1,51 @@
+package aigit
+
+import (
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("PR templates", func() {
+	write := func(root, path string) string {
+		path = filepath.Join(root, path)
+		Expect(os.MkdirAll(filepath.Dir(path), 0o755)).To(Succeed())
+		Expect(os.WriteFile(path, []byte("## Motivation\n"), 0o644)).To(Succeed())
+		return path
+	}
+
+	It("should find the default template regardless of case", func() {
+		root := GinkgoT().TempDir()
+		path := write(root, ".github/PULL_REQUEST_TEMPLATE.md")
+
+		defaultTemplate, templates, err := FindPRTemplates(root)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(defaultTemplate).To(Equal(path))
+		Expect(templates).To(BeEmpty())
+	})
+
+	It("should find templates in PULL_REQUEST_TEMPLATE directories", func() {
+		root := GinkgoT().TempDir()
+		feature := write(root, ".github/PULL_REQUEST_TEMPLATE/feature.md")
+		bug := write(root, ".github/PULL_REQUEST_TEMPLATE/bug.md")
+		docs := write(root, "docs/pull_request_template/docs.md")
+
+		defaultTemplate, templates, err := FindPRTemplates(root)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(defaultTemplate).To(BeEmpty())
+		Expect(templates).To(Equal([]string{bug, feature, docs}))
+	})
+
+	It("should list headings outside code blocks", func() {
+		template := "# Summary\n<!-- what and why -->\n## Testing ##\n```\n# not a heading\n```\n####### too deep\n#hashtag\n    # indented code\n### Risk:\n"
+		Expect(templateHeadings(template)).To(Equal([]string{"Summary", "Testing", "Risk:"}))
+	})
+
+	It("should report headings missing from a description", func() {
+		headings := []string{"Motivation", "Testing", "Risk"}
+		Expect(missingHeadings(headings, "### motivation\nBecause.\n## Risk:\nLow.")).To(Equal([]string{"Testing"}))
+		Expect(missingHeadings(nil, "anything")).To(BeEmpty())
+	})
+})
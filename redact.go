@@ -0,0 +1,192 @@
+package aigit
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+const (
+	RedactMask  = "mask"
+	RedactAbort = "abort"
+	RedactOff   = "off"
+)
+
+// entropyThreshold is the Shannon entropy in bits per character above which a token
+// is considered random. Hex strings such as commit hashes stay below it.
+const entropyThreshold = 4.2
+
+// SecretFinding locates a suspected secret in a diff
+type SecretFinding struct {
+	File     string
+	Line     int
+	Detector string
+}
+
+func (f SecretFinding) String() string {
+	return fmt.Sprintf("%s:%d (%s)", f.File, f.Line, f.Detector)
+}
+
+// SecretsFoundError is returned when secrets are found and redaction is set to abort
+type SecretsFoundError struct {
+	Findings []SecretFinding
+}
+
+func (e *SecretsFoundError) Error() string {
+	lines := make([]string, len(e.Findings))
+	for i, f := range e.Findings {
+		lines[i] = "  " + f.String()
+	}
+	return fmt.Sprintf("possible secrets found in changes, refusing to send them to the model:\n%s", strings.Join(lines, "\n"))
+}
+
+type secretDetector struct {
+	name    string
+	pattern *regexp.Regexp
+}
+
+// builtinDetectors match well known credential formats. When a pattern has a capture
+// group, only the group is masked.
+var builtinDetectors = []secretDetector{
+	{"aws-access-key", regexp.MustCompile(`\b(?:AKIA|ASIA)[0-9A-Z]{16}\b`)},
+	{"aws-secret-key", regexp.MustCompile(`(?i)aws.{0,20}?(?:secret|private).{0,20}?['"]?\s*[:=]\s*['"]?([A-Za-z0-9/+=]{40})\b`)},
+	{"github-token", regexp.MustCompile(`\b(?:gh[pousr]_[A-Za-z0-9]{36,255}|github_pat_[A-Za-z0-9_]{22,255})\b`)},
+	{"slack-token", regexp.MustCompile(`\bxox[abposr]-[A-Za-z0-9-]{10,}\b`)},
+	{"api-key", regexp.MustCompile(`\b(?:sk-ant-[A-Za-z0-9_-]{20,}|sk-[A-Za-z0-9]{32,})\b`)},
+	{"secret-assignment", regexp.MustCompile(`(?i)(?:password|passwd|secret|token|api_?key)\w*['"]?\s*[:=]\s*['"]?([^\s'"(),;]{8,})(?:['"\s,;]|$)`)},
+}
+
+var (
+	pemBeginPattern = regexp.MustCompile(`-----BEGIN [A-Z0-9 ]*PRIVATE KEY( BLOCK)?-----`)
+	pemEndPattern   = regexp.MustCompile(`-----END [A-Z0-9 ]*PRIVATE KEY( BLOCK)?-----`)
+	tokenPattern    = regexp.MustCompile(`[A-Za-z0-9+/_=-]{24,}`)
+	hunkPattern     = regexp.MustCompile(`^@@ -(\d+)(?:,\d+)? \+(\d+)(?:,\d+)? @@`)
+)
+
+// Redactor finds and masks credentials in diffs before they are sent to a model
+type Redactor struct {
+	detectors []secretDetector
+}
+
+// NewRedactor creates a redactor using the built-in detectors and the given custom patterns
+func NewRedactor(custom []string) (*Redactor, error) {
+	detectors := append([]secretDetector{}, builtinDetectors...)
+	for _, expr := range custom {
+		pattern, err := regexp.Compile(expr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid redaction pattern %q: %w", expr, err)
+		}
+		detectors = append(detectors, secretDetector{name: "custom", pattern: pattern})
+	}
+	return &Redactor{detectors: detectors}, nil
+}
+
+// Redact masks suspected secrets in a unified diff and reports where they were found.
+// Line numbers refer to the new file, or the old file for removed lines.
+func (r *Redactor) Redact(diff string) (string, []SecretFinding) {
+	var findings []SecretFinding
+	var file string
+	// Text outside a diff, such as commit messages, is numbered from its first line
+	oldLine, newLine := 1, 1
+	inKey := false
+
+	lines := strings.Split(diff, "\n")
+	for i, line := range lines {
+		// Track the file and position within it
+		switch {
+		case strings.HasPrefix(line, "diff --git "):
+			file = diffPath(line)
+			continue
+		case strings.HasPrefix(line, "+++ "), strings.HasPrefix(line, "--- "):
+			continue
+		case strings.HasPrefix(line, "@@"):
+			if m := hunkPattern.FindStringSubmatch(line); m != nil {
+				oldLine, _ = strconv.Atoi(m[1])
+				newLine, _ = strconv.Atoi(m[2])
+			}
+			continue
+		}
+
+		number := newLine
+		switch {
+		case strings.HasPrefix(line, "-"):
+			number = oldLine
+			oldLine++
+		case strings.HasPrefix(line, "+"):
+			newLine++
+		default:
+			oldLine++
+			newLine++
+		}
+		report := func(detector string) {
+			findings = append(findings, SecretFinding{File: file, Line: number, Detector: detector})
+		}
+
+		// Private keys span several lines, mask the whole block
+		if inKey || pemBeginPattern.MatchString(line) {
+			if !inKey {
+				report("private-key")
+			}
+			inKey = !pemEndPattern.MatchString(line)
+			lines[i] = line[:min(1, len(line))] + "[REDACTED:private-key]"
+			continue
+		}
+
+		for _, d := range r.detectors {
+			line = d.pattern.ReplaceAllStringFunc(line, func(match string) string {
+				start, end := 0, len(match)
+				if sub := d.pattern.FindStringSubmatchIndex(match); len(sub) >= 4 && sub[2] >= 0 {
+					start, end = sub[2], sub[3]
+				}
+				// Skip values an earlier detector already masked
+				if strings.Contains(match[start:end], "[REDACTED:") {
+					return match
+				}
+				report(d.name)
+				return match[:start] + mask(d.name) + match[end:]
+			})
+		}
+
+		line = tokenPattern.ReplaceAllStringFunc(line, func(token string) string {
+			if !isHighEntropy(token) {
+				return token
+			}
+			report("high-entropy")
+			return mask("high-entropy")
+		})
+		lines[i] = line
+	}
+	return strings.Join(lines, "\n"), findings
+}
+
+func mask(detector string) string {
+	return "[REDACTED:" + detector + "]"
+}
+
+// isHighEntropy reports whether a token looks randomly generated
+func isHighEntropy(token string) bool {
+	if strings.Contains(token, "REDACTED") {
+		return false
+	}
+	hasDigit := strings.ContainsAny(token, "0123456789")
+	hasLetter := strings.IndexFunc(token, unicode.IsLetter) >= 0
+	return hasDigit && hasLetter && shannonEntropy(token) > entropyThreshold
+}
+
+// shannonEntropy returns the entropy of s in bits per character
+func shannonEntropy(s string) float64 {
+	counts := map[rune]int{}
+	for _, r := range s {
+		counts[r]++
+	}
+	entropy := 0.0
+	n := float64(len(s))
+	for _, c := range counts {
+		p := float64(c) / n
+		entropy -= p * math.Log2(p)
+	}
+	return entropy
+}
@@ -0,0 +1,165 @@
+package aigit
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ErrForgeNotFound  = errors.New("not found, or no access with the configured token")
+	ErrForgeAuth      = errors.New("authentication failed, check the configured token")
+	ErrForgeRateLimit = errors.New("API rate limit exceeded")
+)
+
+// APIError is an error response of a forge API. Depending on the status it matches
+// ErrForgeNotFound, ErrForgeAuth or ErrForgeRateLimit with errors.Is.
+type APIError struct {
+	StatusCode int
+	Message    string
+	// RateLimited is set when the request was rejected by a rate limit
+	RateLimited bool
+	// Reset is when the rate limit resets, zero if unknown
+	Reset time.Time
+}
+
+func (e *APIError) Error() string {
+	msg := fmt.Sprintf("status %d", e.StatusCode)
+	if e.Message != "" {
+		msg = fmt.Sprintf("%s (status %d)", e.Message, e.StatusCode)
+	}
+	if !e.Reset.IsZero() {
+		msg += fmt.Sprintf(", rate limit resets at %s", e.Reset.Format(time.Kitchen))
+	}
+	return msg
+}
+
+func (e *APIError) Unwrap() error {
+	switch {
+	case e.RateLimited:
+		return ErrForgeRateLimit
+	case e.StatusCode == http.StatusUnauthorized || e.StatusCode == http.StatusForbidden:
+		return ErrForgeAuth
+	case e.StatusCode == http.StatusNotFound:
+		return ErrForgeNotFound
+	}
+	return nil
+}
+
+// newAPIError parses an error response
+func newAPIError(resp *http.Response, body []byte) *APIError {
+	apiErr := &APIError{StatusCode: resp.StatusCode}
+
+	// Errors carry a message, which GitLab sends as an object when validation fails
+	var payload struct {
+		Message json.RawMessage `json:"message"`
+		Error   string          `json:"error"`
+	}
+	if json.Unmarshal(body, &payload) == nil {
+		apiErr.Message = strings.Trim(string(payload.Message), `"`)
+		if apiErr.Message == "" {
+			apiErr.Message = payload.Error
+		}
+	}
+
+	// GitHub rejects requests over the limit with 403, either with no remaining requests or a
+	// Retry-After for its secondary limits. Others use 429.
+	limited := resp.Header.Get("X-RateLimit-Remaining") == "0" || resp.Header.Get("Retry-After") != ""
+	if resp.StatusCode == http.StatusTooManyRequests || (resp.StatusCode == http.StatusForbidden && limited) {
+		apiErr.RateLimited = true
+		for _, header := range []string{"X-RateLimit-Reset", "RateLimit-Reset"} {
+			if reset, err := strconv.ParseInt(resp.Header.Get(header), 10, 64); err == nil {
+				apiErr.Reset = time.Unix(reset, 0)
+				break
+			}
+		}
+		if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && apiErr.Reset.IsZero() {
+			apiErr.Reset = time.Now().Add(time.Duration(seconds) * time.Second)
+		}
+	}
+	return apiErr
+}
+
+// restClient calls the JSON REST API of a forge
+type restClient struct {
+	client *http.Client
+	// baseURL includes the API prefix, such as https://gitlab.com/api/v4
+	baseURL string
+	// header is sent with every request, typically carrying the token
+	header http.Header
+	// mediaType is accepted for JSON responses
+	mediaType string
+}
+
+func newRestClient(baseURL string) *restClient {
+	return &restClient{
+		client:    http.DefaultClient,
+		baseURL:   baseURL,
+		header:    http.Header{},
+		mediaType: "application/json",
+	}
+}
+
+// request calls the API, encoding body and decoding the response into result when not nil
+func (c *restClient) request(method, path string, body, result any) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("failed to encode request: %w", err)
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	data, status, err := c.do(method, path, reader, c.mediaType)
+	if err != nil {
+		return err
+	}
+	if result == nil || status == http.StatusNoContent {
+		return nil
+	}
+	if err := json.Unmarshal(data, result); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+	return nil
+}
+
+// text fetches a resource in a non-JSON media type, such as a diff
+func (c *restClient) text(path, mediaType string) (string, error) {
+	data, _, err := c.do(http.MethodGet, path, nil, mediaType)
+	return string(data), err
+}
+
+// do sends a request accepting the given media type and returns the body of a successful response
+func (c *restClient) do(method, path string, body io.Reader, mediaType string) ([]byte, int, error) {
+	req, err := http.NewRequest(method, c.baseURL+path, body)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to create request: %w", err)
+	}
+	for key, values := range c.header {
+		req.Header[key] = values
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", mediaType)
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to read response: %w", err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, 0, newAPIError(resp, data)
+	}
+	return data, resp.StatusCode, nil
+}
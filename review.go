@@ -0,0 +1,327 @@
+package aigit
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/mattn/go-isatty"
+)
+
+var (
+	ErrAborted        = errors.New("aborted by user")
+	ErrNotInteractive = errors.New("cannot prompt: stdin is not a terminal (use --yes to skip the review)")
+)
+
+// ReviewAction is the user's decision about a generated message
+type ReviewAction int
+
+const (
+	ReviewAccept ReviewAction = iota
+	ReviewEdit
+	ReviewRegenerate
+	ReviewAbort
+)
+
+// ReviewResult is returned by Prompter.Review
+type ReviewResult struct {
+	Action ReviewAction
+	// Guidance holds extra instructions for the model when regenerating
+	Guidance string
+}
+
+// Prompter asks the user for decisions about generated content
+type Prompter interface {
+	// Review shows a generated message and asks whether to accept, edit, regenerate or abort
+	Review(message string) (ReviewResult, error)
+	// Edit lets the user change a message and returns the result
+	Edit(message string) (string, error)
+	// Pick lets the user choose one of several candidate messages and returns its index
+	Pick(candidates []string) (int, error)
+	// Confirm asks a yes/no question
+	Confirm(question string) (bool, error)
+}
+
+// TerminalPrompter implements Prompter with an interactive terminal UI
+type TerminalPrompter struct{}
+
+func NewTerminalPrompter() *TerminalPrompter {
+	return &TerminalPrompter{}
+}
+
+func (p *TerminalPrompter) Review(message string) (ReviewResult, error) {
+	if !isatty.IsTerminal(os.Stdin.Fd()) {
+		return ReviewResult{}, ErrNotInteractive
+	}
+
+	final, err := tea.NewProgram(newReviewModel(message)).Run()
+	if err != nil {
+		return ReviewResult{}, fmt.Errorf("error running review prompt: %w", err)
+	}
+	return final.(reviewModel).result, nil
+}
+
+func (p *TerminalPrompter) Pick(candidates []string) (int, error) {
+	if !isatty.IsTerminal(os.Stdin.Fd()) {
+		return 0, ErrNotInteractive
+	}
+
+	final, err := tea.NewProgram(pickerModel{candidates: candidates}).Run()
+	if err != nil {
+		return 0, fmt.Errorf("error running picker: %w", err)
+	}
+	picker := final.(pickerModel)
+	if !picker.picked {
+		return 0, ErrAborted
+	}
+	return picker.cursor, nil
+}
+
+func (p *TerminalPrompter) Confirm(question string) (bool, error) {
+	if !isatty.IsTerminal(os.Stdin.Fd()) {
+		return false, ErrNotInteractive
+	}
+
+	final, err := tea.NewProgram(confirmModel{question: question}).Run()
+	if err != nil {
+		return false, fmt.Errorf("error running confirmation prompt: %w", err)
+	}
+	return final.(confirmModel).confirmed, nil
+}
+
+// Edit opens the message in $VISUAL or $EDITOR, falling back to vi.
+// Lines starting with # are removed, like git does for commit messages.
+func (p *TerminalPrompter) Edit(message string) (string, error) {
+	editor := os.Getenv("VISUAL")
+	if editor == "" {
+		editor = os.Getenv("EDITOR")
+	}
+	if editor == "" {
+		editor = "vi"
+	}
+
+	file, err := os.CreateTemp("", "aigit-*.txt")
+	if err != nil {
+		return "", fmt.Errorf("error creating temporary file: %w", err)
+	}
+	defer os.Remove(file.Name())
+
+	content := message + "\n\n# Edit the message above. Lines starting with # are ignored.\n# An empty message aborts.\n"
+	if _, err := file.WriteString(content); err != nil {
+		file.Close()
+		return "", fmt.Errorf("error writing temporary file: %w", err)
+	}
+	file.Close()
+
+	// The editor may include arguments, e.g. "code --wait"
+	args := append(strings.Fields(editor), file.Name())
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("error running editor %s: %w", editor, err)
+	}
+
+	data, err := os.ReadFile(file.Name())
+	if err != nil {
+		return "", fmt.Errorf("error reading edited message: %w", err)
+	}
+
+	var lines []string
+	for _, line := range strings.Split(string(data), "\n") {
+		if !strings.HasPrefix(line, "#") {
+			lines = append(lines, line)
+		}
+	}
+	edited := strings.TrimSpace(strings.Join(lines, "\n"))
+	if edited == "" {
+		return "", ErrAborted
+	}
+	return edited, nil
+}
+
+var (
+	reviewMessageStyle  = lipgloss.NewStyle().Border(lipgloss.RoundedBorder()).Padding(0, 1)
+	reviewSelectedStyle = reviewMessageStyle.BorderForeground(lipgloss.Color("205"))
+	reviewHelpStyle     = lipgloss.NewStyle().Foreground(lipgloss.Color("241"))
+)
+
+type reviewModel struct {
+	message  string
+	guidance textinput.Model
+	asking   bool
+	done     bool
+	result   ReviewResult
+}
+
+func newReviewModel(message string) reviewModel {
+	input := textinput.New()
+	input.Placeholder = "e.g. mention the config change, use the fix type"
+	input.Prompt = "Guidance: "
+	return reviewModel{
+		message:  message,
+		guidance: input,
+	}
+}
+
+func (m reviewModel) Init() tea.Cmd {
+	return nil
+}
+
+// finish records the decision and clears the view
+func (m reviewModel) finish(result ReviewResult) (tea.Model, tea.Cmd) {
+	m.result = result
+	m.done = true
+	return m, tea.Quit
+}
+
+func (m reviewModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	key, ok := msg.(tea.KeyMsg)
+	if !ok {
+		return m, nil
+	}
+
+	// While asking for guidance, keys go to the text input
+	if m.asking {
+		switch key.String() {
+		case "enter":
+			return m.finish(ReviewResult{Action: ReviewRegenerate, Guidance: strings.TrimSpace(m.guidance.Value())})
+		case "esc":
+			m.asking = false
+			m.guidance.Blur()
+			return m, nil
+		case "ctrl+c":
+			return m.finish(ReviewResult{Action: ReviewAbort})
+		}
+		var cmd tea.Cmd
+		m.guidance, cmd = m.guidance.Update(msg)
+		return m, cmd
+	}
+
+	switch key.String() {
+	case "a", "y", "enter":
+		return m.finish(ReviewResult{Action: ReviewAccept})
+	case "e":
+		return m.finish(ReviewResult{Action: ReviewEdit})
+	case "r":
+		m.asking = true
+		return m, m.guidance.Focus()
+	case "q", "esc", "ctrl+c":
+		return m.finish(ReviewResult{Action: ReviewAbort})
+	}
+	return m, nil
+}
+
+func (m reviewModel) View() string {
+	if m.done {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteString(reviewMessageStyle.Render(m.message))
+	b.WriteString("\n")
+	if m.asking {
+		b.WriteString(m.guidance.View())
+		b.WriteString("\n")
+		b.WriteString(reviewHelpStyle.Render("enter: regenerate • esc: back"))
+	} else {
+		b.WriteString(reviewHelpStyle.Render("a/enter: accept • e: edit • r: regenerate • q: abort"))
+	}
+	b.WriteString("\n")
+	return b.String()
+}
+
+type pickerModel struct {
+	candidates []string
+	cursor     int
+	picked     bool
+	done       bool
+}
+
+func (m pickerModel) Init() tea.Cmd {
+	return nil
+}
+
+func (m pickerModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	key, ok := msg.(tea.KeyMsg)
+	if !ok {
+		return m, nil
+	}
+
+	switch key.String() {
+	case "up", "k":
+		if m.cursor > 0 {
+			m.cursor--
+		}
+	case "down", "j":
+		if m.cursor < len(m.candidates)-1 {
+			m.cursor++
+		}
+	case "enter":
+		m.picked = true
+		m.done = true
+		return m, tea.Quit
+	case "q", "esc", "ctrl+c":
+		m.done = true
+		return m, tea.Quit
+	}
+	return m, nil
+}
+
+func (m pickerModel) View() string {
+	if m.done {
+		return ""
+	}
+	var b strings.Builder
+	for i, candidate := range m.candidates {
+		if i == m.cursor {
+			b.WriteString(reviewSelectedStyle.Render(candidate))
+		} else {
+			b.WriteString(reviewMessageStyle.Render(candidate))
+		}
+		b.WriteString("\n")
+	}
+	b.WriteString(reviewHelpStyle.Render("↑/↓: move • enter: commit • q: abort"))
+	b.WriteString("\n")
+	return b.String()
+}
+
+type confirmModel struct {
+	question  string
+	confirmed bool
+	done      bool
+}
+
+func (m confirmModel) Init() tea.Cmd {
+	return nil
+}
+
+func (m confirmModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	key, ok := msg.(tea.KeyMsg)
+	if !ok {
+		return m, nil
+	}
+
+	switch key.String() {
+	case "y", "Y":
+		m.confirmed = true
+		m.done = true
+		return m, tea.Quit
+	case "n", "N", "enter", "q", "esc", "ctrl+c":
+		m.done = true
+		return m, tea.Quit
+	}
+	return m, nil
+}
+
+func (m confirmModel) View() string {
+	if m.done {
+		return ""
+	}
+	return m.question + " " + reviewHelpStyle.Render("[y/N]") + "\n"
+}
@@ -13,9 +13,16 @@ import (
 type spinnerModel struct {
 	spinner  spinner.Model
 	message  string
+	output   string
 	quitting bool
 }
 
+// spinnerDeltaMsg carries a piece of streamed text to render below the spinner
+type spinnerDeltaMsg string
+
+// spinnerDoneMsg clears the view and stops the spinner
+type spinnerDoneMsg struct{}
+
 func initialSpinnerModel(message string) spinnerModel {
 	s := spinner.New()
 	s.Spinner = spinner.Dot
@@ -41,6 +48,12 @@ func (m spinnerModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		default:
 			return m, nil
 		}
+	case spinnerDeltaMsg:
+		m.output += string(msg)
+		return m, nil
+	case spinnerDoneMsg:
+		m.quitting = true
+		return m, tea.Quit
 	case spinner.TickMsg:
 		var cmd tea.Cmd
 		m.spinner, cmd = m.spinner.Update(msg)
@@ -54,6 +67,9 @@ func (m spinnerModel) View() string {
 	if m.quitting {
 		return ""
 	}
+	if m.output != "" {
+		return fmt.Sprintf("\r%s %s\n%s", m.spinner.View(), m.message, m.output)
+	}
 	return fmt.Sprintf("\r%s %s", m.spinner.View(), m.message)
 }
 
@@ -80,3 +96,28 @@ func WithSpinner(message string, fn func() error) error {
 
 	return err
 }
+
+// WithStreamingSpinner runs the provided function while showing a spinner with the given message.
+// Text passed to the onDelta callback is rendered below the spinner as it arrives, and cleared
+// once the function completes.
+func WithStreamingSpinner(message string, fn func(onDelta func(string)) error) error {
+	p := tea.NewProgram(initialSpinnerModel(message))
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		if _, err := p.Run(); err != nil {
+			fmt.Fprintf(os.Stderr, "Error running spinner: %v\n", err)
+		}
+	}()
+
+	err := fn(func(delta string) {
+		p.Send(spinnerDeltaMsg(delta))
+	})
+
+	// Clear the view and wait for the program to exit
+	p.Send(spinnerDoneMsg{})
+	<-done
+
+	return err
+}